@@ -0,0 +1,70 @@
+package app
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"syscall"
+
+	"github.com/glowfi/ghkd/internal/config"
+)
+
+// runBuiltin performs a built-in action inside the daemon
+func (d *Daemon) runBuiltin(c *components, kb *config.Keybinding) {
+	// Validated when the config was loaded
+	verb, arg, _ := config.ParseAction(kb.Action)
+
+	switch verb {
+	case config.ActionReload:
+		d.sendSignal(c, syscall.SIGHUP)
+
+	case config.ActionQuit:
+		c.quitOnce.Do(func() { close(c.quit) })
+
+	case config.ActionPause:
+		c.reg.SetPaused(true)
+		fmt.Println("Hotkeys paused")
+
+	case config.ActionResume:
+		c.reg.SetPaused(false)
+		fmt.Println("Hotkeys resumed")
+
+	case config.ActionTogglePause:
+		if c.reg.TogglePaused() {
+			fmt.Println("Hotkeys paused")
+		} else {
+			fmt.Println("Hotkeys resumed")
+		}
+
+	case config.ActionMode:
+		c.reg.SetMode(arg)
+		fmt.Printf("Mode: %s\n", arg)
+
+	case config.ActionEnable:
+		c.reg.SetEnabled(arg, true)
+		fmt.Printf("Enabled: %s\n", arg)
+
+	case config.ActionDisable:
+		c.reg.SetEnabled(arg, false)
+		fmt.Printf("Disabled: %s\n", arg)
+
+	case config.ActionKill:
+		// Stopping may take up to the kill grace period
+		go func() {
+			fmt.Printf("Killed %d running action(s) of %s\n", c.exec.Kill(arg), arg)
+		}()
+
+	case config.ActionLog:
+		log.Printf("Log: %s", arg)
+	}
+}
+
+// sendSignal hands a signal to the signal loop as if the OS had sent it.
+// A signal that is already pending covers this one, so it must only be
+// used for signals that may be merged, like SIGHUP.
+func (d *Daemon) sendSignal(c *components, sig os.Signal) {
+	select {
+	case c.signals <- sig:
+	default:
+	}
+}
@@ -0,0 +1,167 @@
+package app
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/glowfi/ghkd/internal/config"
+	"github.com/glowfi/ghkd/internal/executor"
+	"github.com/glowfi/ghkd/internal/hotkey"
+	"github.com/glowfi/ghkd/internal/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuiltin_RunBuiltin(t *testing.T) {
+	combo, err := hotkey.ParseKeyCombo("super+t")
+	require.NoError(t, err, "expect combo to parse")
+	snapshot := hotkey.Snapshot{Keys: []uint16{hotkey.KEY_LEFTMETA, hotkey.KEY_T}}
+
+	tests := []struct {
+		name      string
+		actions   []string // built-in actions run in order
+		wantMode  string
+		wantMatch bool // the Sleep binding still matches super+t
+		wantQuit  bool
+		wantHUP   bool // a reload is pending
+	}{
+		{
+			name:      "should pause every binding :POS",
+			actions:   []string{"pause"},
+			wantMode:  config.DefaultMode,
+			wantMatch: false,
+		},
+		{
+			name:      "should resume after a pause :POS",
+			actions:   []string{"pause", "resume"},
+			wantMode:  config.DefaultMode,
+			wantMatch: true,
+		},
+		{
+			name:      "should toggle the pause twice back to running :POS",
+			actions:   []string{"toggle-pause", "toggle-pause"},
+			wantMode:  config.DefaultMode,
+			wantMatch: true,
+		},
+		{
+			name:      "should switch the mode :POS",
+			actions:   []string{"mode resize"},
+			wantMode:  "resize",
+			wantMatch: false,
+		},
+		{
+			name:      "should disable a binding :POS",
+			actions:   []string{"disable Sleep"},
+			wantMode:  config.DefaultMode,
+			wantMatch: false,
+		},
+		{
+			name:      "should enable a disabled binding :POS",
+			actions:   []string{"disable Sleep", "enable Sleep"},
+			wantMode:  config.DefaultMode,
+			wantMatch: true,
+		},
+		{
+			name:      "should only log a message :POS",
+			actions:   []string{"log hello"},
+			wantMode:  config.DefaultMode,
+			wantMatch: true,
+		},
+		{
+			name:      "should request a reload :POS",
+			actions:   []string{"reload"},
+			wantMode:  config.DefaultMode,
+			wantMatch: true,
+			wantHUP:   true,
+		},
+		{
+			name:      "should quit while a reload is pending :NEG",
+			actions:   []string{"reload", "quit"},
+			wantMode:  config.DefaultMode,
+			wantMatch: true,
+			wantQuit:  true,
+			wantHUP:   true,
+		},
+		{
+			name:      "should accept quit twice :NEG",
+			actions:   []string{"quit", "quit"},
+			wantMode:  config.DefaultMode,
+			wantMatch: true,
+			wantQuit:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		c := &components{
+			reg: registry.NewRegistry([]config.Keybinding{
+				{Name: "Sleep", KeyCombination: combo, Run: config.Command{Line: "sleep 60"}},
+			}),
+			exec:    executor.New(),
+			signals: make(chan os.Signal, 1),
+			quit:    make(chan struct{}),
+		}
+
+		d := &Daemon{}
+		for _, action := range tt.actions {
+			d.runBuiltin(c, &config.Keybinding{Name: "Builtin", Action: action})
+		}
+
+		assert.Equal(t, tt.wantMode, c.reg.Mode(), tt.name)
+		match := c.reg.Match(snapshot, config.OnPress, nil)
+		assert.Equal(t, tt.wantMatch, match != nil, tt.name)
+
+		select {
+		case <-c.quit:
+			assert.True(t, tt.wantQuit, tt.name)
+		default:
+			assert.False(t, tt.wantQuit, tt.name)
+		}
+
+		select {
+		case sig := <-c.signals:
+			assert.True(t, tt.wantHUP, tt.name)
+			assert.Equal(t, syscall.SIGHUP, sig, tt.name)
+		default:
+			assert.False(t, tt.wantHUP, tt.name)
+		}
+	}
+}
+
+func TestBuiltin_Kill(t *testing.T) {
+	tests := []struct {
+		name        string
+		kill        string
+		wantRunning bool
+	}{
+		{
+			name:        "should stop the running actions of the binding :POS",
+			kill:        "kill Sleep",
+			wantRunning: false,
+		},
+		{
+			name:        "should leave other bindings running :NEG",
+			kill:        "kill Other",
+			wantRunning: true,
+		},
+	}
+
+	for _, tt := range tests {
+		c := &components{exec: executor.New()}
+		kb := &config.Keybinding{Name: "Sleep", Run: config.Command{Line: "sleep 60"}}
+		require.NoError(t, c.exec.Execute(context.Background(), kb, executor.Trigger{}), tt.name)
+		require.Eventually(t, func() bool { return c.exec.IsRunning("Sleep") }, time.Second, 5*time.Millisecond, tt.name)
+
+		(&Daemon{}).runBuiltin(c, &config.Keybinding{Name: "Builtin", Action: tt.kill})
+
+		if tt.wantRunning {
+			time.Sleep(100 * time.Millisecond)
+			assert.True(t, c.exec.IsRunning("Sleep"), tt.name)
+		} else {
+			assert.Eventually(t, func() bool { return !c.exec.IsRunning("Sleep") }, 5*time.Second, 5*time.Millisecond, tt.name)
+		}
+		assert.NoError(t, c.exec.Shutdown(), tt.name)
+	}
+}
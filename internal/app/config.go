@@ -6,6 +6,14 @@ type Config struct {
 	InputDir    string
 	CfgPath     string
 	PidFilePath string
+	SocketPaths []string // Control sockets, the daemon listens on the first and the CLI tries them in order
+	ReplayPath  string   // Trace to replay instead of reading devices, "-" for stdin
+	TracePath   string   // Output of record-trace
+	MaskTrace   bool     // Hide typed letters and numbers in the recorded trace
+	Binding     string   // Binding whose logs or history are shown
+	Runs        int      // Number of runs shown by logs
+	SetEnv      []string // KEY=VALUE or KEY arguments of setenv
+	JSON        bool     // Print history as JSON
 }
 
 func NewConfig(InputDir, configPath, PidFilePath string) *Config {
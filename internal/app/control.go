@@ -0,0 +1,139 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/glowfi/ghkd/internal/config"
+	"github.com/glowfi/ghkd/internal/executor"
+	"github.com/glowfi/ghkd/internal/ipc"
+)
+
+// handleRequest answers requests on the control socket
+func (d *Daemon) handleRequest(c *components) ipc.Handler {
+	return func(uid uint32, req ipc.Request) ipc.Response {
+		switch req.Command {
+		case ipc.CommandSetEnv:
+			ignored := c.exec.SetEnv(uid, req.Env)
+			fmt.Printf("Imported %d variable(s) for uid %d with setenv\n", len(req.Env)-len(ignored), uid)
+			if len(ignored) > 0 {
+				return ipc.Response{Error: fmt.Sprintf("not in import_env vars, ignored: %s", strings.Join(ignored, ", "))}
+			}
+			return ipc.Response{}
+		case ipc.CommandHistory:
+			data, err := json.Marshal(c.exec.History(req.Binding))
+			if err != nil {
+				return ipc.Response{Error: err.Error()}
+			}
+			return ipc.Response{Data: data}
+		default:
+			return ipc.Response{Error: fmt.Sprintf("unknown command %q", req.Command)}
+		}
+	}
+}
+
+// actionUIDs returns the uids of the users actions run as. They may use the
+// control socket to send their session environment to a root daemon.
+func actionUIDs(cfg config.Config) []uint32 {
+	names := []string{cfg.Defaults.User}
+	for _, kb := range cfg.Keybindings {
+		names = append(names, kb.User)
+	}
+
+	var uids []uint32
+	for _, name := range names {
+		if name == "" {
+			continue
+		}
+		u, err := config.LookupUser(name)
+		if err != nil {
+			continue
+		}
+		if uid, err := strconv.ParseUint(u.Uid, 10, 32); err == nil {
+			uids = append(uids, uint32(uid))
+		}
+	}
+	return uids
+}
+
+// call sends a request to the first control socket a daemon listens on
+func (d *Daemon) call(req ipc.Request) (ipc.Response, error) {
+	err := errors.New("no control socket")
+	for _, path := range d.config.SocketPaths {
+		var resp ipc.Response
+		resp, err = ipc.Call(path, req)
+		// Nobody listens there, try the next one
+		if errors.Is(err, syscall.ENOENT) || errors.Is(err, syscall.ECONNREFUSED) {
+			continue
+		}
+		return resp, err
+	}
+	return ipc.Response{}, err
+}
+
+// setEnv sends variables to the running daemon. KEY=VALUE sets a value,
+// a bare KEY sends the value from this process' environment.
+func (d *Daemon) setEnv() error {
+	vars := map[string]string{}
+	for _, arg := range d.config.SetEnv {
+		key, value, found := strings.Cut(arg, "=")
+		if !found {
+			value, found = os.LookupEnv(key)
+			if !found {
+				return fmt.Errorf("setenv: %s is not set", key)
+			}
+		}
+		vars[key] = value
+	}
+
+	if _, err := d.call(ipc.Request{Command: ipc.CommandSetEnv, Env: vars}); err != nil {
+		return fmt.Errorf("setenv: %w", err)
+	}
+	fmt.Printf("Sent %d variable(s) to ghkd daemon.\n", len(vars))
+	return nil
+}
+
+// showHistory prints the recent runs of the running daemon
+func (d *Daemon) showHistory() error {
+	resp, err := d.call(ipc.Request{Command: ipc.CommandHistory, Binding: d.config.Binding})
+	if err != nil {
+		return fmt.Errorf("history: %w", err)
+	}
+
+	if d.config.JSON {
+		fmt.Println(string(resp.Data))
+		return nil
+	}
+
+	var entries []executor.HistoryEntry
+	if err := json.Unmarshal(resp.Data, &entries); err != nil {
+		return fmt.Errorf("history: %w", err)
+	}
+	if len(entries) == 0 {
+		fmt.Println("No runs yet")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tBINDING\tKEYS\tDEVICE\tPID\tSTATUS\tDURATION")
+	for _, entry := range entries {
+		pid, duration := "-", "-"
+		if entry.PID != 0 {
+			pid = strconv.Itoa(entry.PID)
+		}
+		if !entry.Running && !entry.Suppressed {
+			duration = (time.Duration(entry.DurationMs) * time.Millisecond).String()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			entry.Time.Local().Format(time.DateTime), entry.Binding, entry.Keys,
+			entry.Device, pid, entry.Status, duration)
+	}
+	return w.Flush()
+}
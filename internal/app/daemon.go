@@ -7,16 +7,26 @@ import (
 	"os"
 	"os/exec"
 	"os/signal"
+	"slices"
+	"sync"
 	"syscall"
+	"time"
 
 	"github.com/glowfi/ghkd/internal/cli"
 	"github.com/glowfi/ghkd/internal/config"
 	"github.com/glowfi/ghkd/internal/executor"
+	"github.com/glowfi/ghkd/internal/expander"
+	"github.com/glowfi/ghkd/internal/hotkey"
+	"github.com/glowfi/ghkd/internal/ipc"
 	"github.com/glowfi/ghkd/internal/listener"
 	"github.com/glowfi/ghkd/internal/pid"
 	"github.com/glowfi/ghkd/internal/registry"
 )
 
+// replayWait bounds how long the actions started by a replay may run after
+// the end of its trace
+const replayWait = 30 * time.Second
+
 type Daemon struct {
 	config     *Config
 	pidManager *pid.PidManager
@@ -50,6 +60,24 @@ func (d *Daemon) HandleCommand(cmd cli.Command) (shouldExit bool, err error) {
 		fmt.Println("Sent SIGHUP to ghkd daemon.")
 		return true, nil
 
+	case cli.CommandDevices:
+		return true, d.listDevices()
+
+	case cli.CommandWatch:
+		return true, d.watch()
+
+	case cli.CommandRecordTrace:
+		return true, d.recordTrace()
+
+	case cli.CommandLogs:
+		return true, d.showLogs()
+
+	case cli.CommandSetEnv:
+		return true, d.setEnv()
+
+	case cli.CommandHistory:
+		return true, d.showHistory()
+
 	case cli.CommandBackground:
 		if err := d.startBackground(); err != nil {
 			return true, err
@@ -72,6 +100,11 @@ func (d *Daemon) startBackground() error {
 }
 
 func (d *Daemon) Run(ctx context.Context) error {
+	// A replay doesn't read devices, so it may run next to the daemon
+	if d.config.ReplayPath != "" {
+		return d.runEventLoop(ctx)
+	}
+
 	// Check if already running
 	if d.pidManager.IsRunning() {
 		return fmt.Errorf("ghkd is already running")
@@ -86,6 +119,22 @@ func (d *Daemon) Run(ctx context.Context) error {
 	return d.runEventLoop(ctx)
 }
 
+// components are the parts of a running event loop
+type components struct {
+	reg  *registry.Registry
+	exp  *expander.Expander
+	lst  listener.Source
+	exec *executor.Executor
+	ipc  *ipc.Server // nil when the control socket is not served
+	dry  bool        // expansions are logged instead of typed, as in a replay
+
+	// signals delivers OS signals and the reload built-in action
+	signals chan os.Signal
+	// quit is closed by the quit built-in action
+	quit     chan struct{}
+	quitOnce sync.Once
+}
+
 func (d *Daemon) runEventLoop(ctx context.Context) error {
 	// Load Config
 	cfg, err := config.LoadConfig(d.config.CfgPath)
@@ -93,76 +142,280 @@ func (d *Daemon) runEventLoop(ctx context.Context) error {
 		return fmt.Errorf("config error: %w", err)
 	}
 
-	exec := executor.New()
-	reg := registry.NewRegistry(cfg.Keybindings)
+	c := &components{
+		reg:  registry.NewRegistry(cfg.Keybindings),
+		exp:  expander.New(cfg.Expansions),
+		exec: executor.New(),
+		dry:  d.config.ReplayPath != "",
+		quit: make(chan struct{}),
+	}
+	c.exec.UpdateImport(cfg.ImportEnv)
+	c.exec.SetLimits(cfg.Limits)
+	c.exec.LoadScripts(cfg.Keybindings)
+	c.exec.SetErrorHook(cfg.OnError)
+
+	lst, closeSource, err := d.newSource(cfg)
+	if err != nil {
+		return err
+	}
+	defer closeSource()
+	c.lst = lst
 
-	lst := listener.NewListener(d.config.InputDir)
 	if err := lst.Start(ctx); err != nil {
 		return fmt.Errorf("listener error: %w", err)
 	}
 
+	// Virtual keyboard is created after the listener so it is not listened to
+	if len(cfg.Expansions) > 0 && !c.dry {
+		if err := c.exp.Open(); err != nil {
+			log.Printf("Warning: text expansion disabled: %v", err)
+		}
+	}
+
+	// A replay may run next to the daemon, so it leaves the socket alone
+	if d.config.ReplayPath == "" && len(d.config.SocketPaths) > 0 {
+		srv, err := ipc.Listen(d.config.SocketPaths[0], d.handleRequest(c))
+		if err != nil {
+			log.Printf("Warning: control socket disabled: %v", err)
+		} else {
+			srv.Allow(actionUIDs(cfg))
+			c.ipc = srv
+			defer srv.Close()
+		}
+	}
+
 	// Handle Signals
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	c.signals = make(chan os.Signal, 1)
+	signal.Notify(c.signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
 
 	// Event Loop
-	go d.processEvents(ctx, lst, reg, exec)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		d.processEvents(ctx, c)
+	}()
 
 	// Signal Loop
-	d.handleSignals(sigChan, reg)
+	if finished := d.handleSignals(done, c); finished && d.config.ReplayPath != "" {
+		d.waitForActions(c)
+	}
 
 	// Cleanup
 	lst.Stop()
-	if err := exec.Shutdown(); err != nil {
+	if err := c.exp.Close(); err != nil {
+		log.Println(err)
+	}
+	if err := c.exec.Shutdown(); err != nil {
 		log.Println(err)
 	}
 
 	return nil
 }
 
-func (d *Daemon) processEvents(ctx context.Context, lst *listener.Listener, reg *registry.Registry, exec *executor.Executor) {
+// newSource creates the input source: a replay when a trace is given,
+// otherwise a listener on the input devices
+func (d *Daemon) newSource(cfg config.Config) (listener.Source, func(), error) {
+	switch d.config.ReplayPath {
+	case "":
+		return listener.NewListener(d.config.InputDir, cfg.Devices), func() {}, nil
+	case "-":
+		return listener.NewReplay(os.Stdin, cfg.Devices, true), func() {}, nil
+	}
+
+	file, err := os.Open(d.config.ReplayPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("replay error: %w", err)
+	}
+	return listener.NewReplay(file, cfg.Devices, true), func() { file.Close() }, nil
+}
+
+// heldCombo is the key combination completed by the last press, tracked
+// until one of its keys is released
+type heldCombo struct {
+	snapshot hotkey.Snapshot
+	code     uint16 // key whose press completed the combo, it auto-repeats
+	repeats  int
+}
+
+func (d *Daemon) processEvents(ctx context.Context, c *components) {
+	// Actions started for the last events must be dispatched before the
+	// loop returns, or a replay could end before its actions run
+	var dispatched sync.WaitGroup
+	defer dispatched.Wait()
+
+	var held *heldCombo
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case _, ok := <-lst.Events():
+		case ev, ok := <-c.lst.Events():
 			if !ok {
 				return
 			}
-			pressed := lst.PressedKeys()
-			if len(pressed) == 0 {
+
+			switch ev.Value {
+			case hotkey.KEY_REPEAT:
+				if held != nil && ev.Code == held.code {
+					held.repeats++
+					d.dispatch(ctx, c, &dispatched, held, config.OnHold, ev.Time)
+				}
+				d.expandTyped(c, ev)
+				continue
+
+			case hotkey.KEY_RELEASED:
+				if held != nil && slices.Contains(held.snapshot.Keys, ev.Code) {
+					d.dispatch(ctx, c, &dispatched, held, config.OnRelease, ev.Time)
+					held = nil
+				}
+				continue
+			}
+
+			if d.expandTyped(c, ev) {
 				continue
 			}
 
-			if match := reg.Match(pressed); match != nil {
-				go func(cfg *config.Keybinding) {
-					if err := exec.Execute(ctx, cfg); err != nil {
-						errMsg := fmt.Sprintf("Error: %v\n", err)
-						log.Println(errMsg)
-					}
-					msg := fmt.Sprintf("Key Matched: %s", cfg.KeyCombination.Raw)
-					log.Println(msg)
-				}(match)
+			if len(ev.Snapshot.Keys) == 0 {
+				continue
 			}
+
+			held = &heldCombo{snapshot: ev.Snapshot, code: ev.Code}
+			d.dispatch(ctx, c, &dispatched, held, config.OnPress, ev.Time)
 		}
 	}
 }
 
-func (d *Daemon) handleSignals(sigChan <-chan os.Signal, reg *registry.Registry) {
-	for sig := range sigChan {
+// dispatch runs the binding that fires on event for a held combo. The
+// binding is picked in the background, as its condition may run a command.
+func (d *Daemon) dispatch(ctx context.Context, c *components, dispatched *sync.WaitGroup, held *heldCombo, event string, when time.Time) {
+	trig := executor.Trigger{
+		Keys:       keyNames(held.snapshot.Keys),
+		Device:     held.snapshot.Device,
+		DevicePath: held.snapshot.DevicePath,
+		Mode:       c.reg.Mode(),
+		Kind:       event,
+		Time:       when,
+		Repeat:     held.repeats,
+	}
+	snapshot := held.snapshot
+
+	dispatched.Add(1)
+	go func() {
+		defer dispatched.Done()
+
+		match := c.reg.Match(snapshot, event, func(kb *config.Keybinding, command string) bool {
+			return c.exec.Succeeds(ctx, kb, command)
+		})
+		if match == nil {
+			return
+		}
+		if match.Action != "" {
+			d.runBuiltin(c, match)
+			return
+		}
+
+		if err := c.exec.Execute(ctx, match, trig); err != nil {
+			errMsg := fmt.Sprintf("Error: %v\n", err)
+			log.Println(errMsg)
+		}
+		msg := fmt.Sprintf("Key Matched: %s", match.KeyCombination.Raw)
+		log.Println(msg)
+	}()
+}
+
+// expandTyped expands the trigger the typed text of ev ends with, if any,
+// and reports whether it did
+func (d *Daemon) expandTyped(c *components, ev listener.Event) bool {
+	match := c.exp.Match(ev.Typed)
+	if match == nil {
+		return false
+	}
+
+	c.lst.ResetTyped()
+	if c.dry {
+		log.Printf("Expanded (dry run): %s", match.Trigger)
+	} else {
+		go d.expand(c.lst, c.exp, match)
+	}
+	return true
+}
+
+// expand types an expansion once the physical keys are released, so held
+// modifiers like shift don't leak into the synthesized keys
+func (d *Daemon) expand(lst listener.Source, exp *expander.Expander, match *config.Expansion) {
+	deadline := time.Now().Add(time.Second)
+	for len(lst.PressedKeys()) > 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if err := exp.Expand(match); err != nil {
+		log.Printf("Error: expansion %s: %v", match.Trigger, err)
+		return
+	}
+	log.Printf("Expanded: %s", match.Trigger)
+}
+
+// reload applies a changed config file to the running components
+func (d *Daemon) reload(c *components) {
+	fmt.Println("Reloading config...")
+	newCfg, err := config.LoadConfig(d.config.CfgPath)
+	if err != nil {
+		log.Printf("Reload failed: %v", err)
+		return
+	}
+	c.reg.Update(newCfg.Keybindings)
+	c.lst.UpdateDevices(newCfg.Devices)
+	c.exec.UpdateImport(newCfg.ImportEnv)
+	c.exec.SetLimits(newCfg.Limits)
+	c.exec.LoadScripts(newCfg.Keybindings)
+	c.exec.SetErrorHook(newCfg.OnError)
+	if c.ipc != nil {
+		c.ipc.Allow(actionUIDs(newCfg))
+	}
+	c.exp.Update(newCfg.Expansions)
+	if len(newCfg.Expansions) > 0 && !c.dry {
+		if err := c.exp.Open(); err != nil {
+			log.Printf("Warning: text expansion disabled: %v", err)
+		}
+	}
+	fmt.Printf("Reloaded %d keybindings\n", len(newCfg.Keybindings))
+}
+
+// handleSignals runs until a shutdown signal arrives or the source runs out
+// of events, as a replay does at the end of its trace. It reports whether
+// the source finished.
+func (d *Daemon) handleSignals(done <-chan struct{}, c *components) (finished bool) {
+	for {
+		var sig os.Signal
+		select {
+		case <-done:
+			fmt.Println("Input source finished, shutting down...")
+			return true
+		case <-c.quit:
+			fmt.Println("\nShutting down...")
+			return false
+		case sig = <-c.signals:
+		}
+
 		if sig == syscall.SIGHUP {
-			fmt.Println("Reloading config...")
-			newCfg, err := config.LoadConfig(d.config.CfgPath)
-			if err != nil {
-				log.Printf("Reload failed: %v", err)
-				continue
-			}
-			reg.Update(newCfg.Keybindings)
-			fmt.Printf("Reloaded %d keybindings\n", len(newCfg.Keybindings))
+			d.reload(c)
 			continue
 		}
 
 		fmt.Println("\nShutting down...")
-		return
+		return false
+	}
+}
+
+// waitForActions lets the actions of a finished replay run to their end,
+// up to replayWait, so shutting down doesn't stop what was just started
+func (d *Daemon) waitForActions(c *components) {
+	select {
+	case <-c.exec.Idle():
+	case <-time.After(replayWait):
+		log.Printf("Warning: actions still running %s after the replay ended, stopping them", replayWait)
+	case <-c.signals:
+		fmt.Println("\nShutting down...")
+	case <-c.quit:
+		fmt.Println("\nShutting down...")
 	}
 }
@@ -0,0 +1,70 @@
+package app
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDaemon_Replay(t *testing.T) {
+	tests := []struct {
+		name      string
+		cfgPath   string
+		tracePath string
+		want      string // output of the action, empty if it must not run
+	}{
+		{
+			name:      "should let the action of the last combo finish :POS",
+			cfgPath:   "./testdata/replay/action.yaml",
+			tracePath: "./testdata/replay/ctrl_t.jsonl",
+			want:      "ctrl+t\n",
+		},
+		{
+			name:      "should run combos after a dry run expansion :POS",
+			cfgPath:   "./testdata/replay/expansion.yaml",
+			tracePath: "./testdata/replay/typed.jsonl",
+			want:      "ctrl+t\n",
+		},
+		{
+			name:      "should fire hold and release bindings with the repeat count :POS",
+			cfgPath:   "./testdata/replay/events.yaml",
+			tracePath: "./testdata/replay/ctrl_t_held.jsonl",
+			want:      "hold 1\nrelease 1\n",
+		},
+		{
+			name:      "should not fire hold bindings without auto-repeat :NEG",
+			cfgPath:   "./testdata/replay/events.yaml",
+			tracePath: "./testdata/replay/ctrl_t.jsonl",
+			want:      "release 0\n",
+		},
+		{
+			name:      "should not run actions of other combos :NEG",
+			cfgPath:   "./testdata/replay/other_combo.yaml",
+			tracePath: "./testdata/replay/ctrl_t.jsonl",
+			want:      "",
+		},
+	}
+
+	for _, tt := range tests {
+		out := filepath.Join(t.TempDir(), "out")
+		t.Setenv("GHKD_TEST_OUT", out)
+
+		cfg := NewConfig("", tt.cfgPath, filepath.Join(t.TempDir(), "ghkd.pid"))
+		cfg.ReplayPath = tt.tracePath
+
+		err := NewDaemon(cfg).Run(context.Background())
+		require.NoError(t, err, tt.name)
+
+		got, err := os.ReadFile(out)
+		if tt.want == "" {
+			assert.ErrorIs(t, err, os.ErrNotExist, tt.name)
+			continue
+		}
+		require.NoError(t, err, tt.name)
+		assert.Equal(t, tt.want, string(got), tt.name)
+	}
+}
@@ -0,0 +1,216 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/glowfi/ghkd/internal/config"
+	"github.com/glowfi/ghkd/internal/executor"
+	"github.com/glowfi/ghkd/internal/hotkey"
+	"github.com/glowfi/ghkd/internal/listener"
+	"github.com/holoplot/go-evdev"
+)
+
+// deviceConfig loads the device section of the config, falling back to the
+// defaults when there is no config file
+func (d *Daemon) deviceConfig() (config.Devices, error) {
+	cfg, err := config.LoadConfig(d.config.CfgPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return config.Devices{}, nil
+	}
+	if err != nil {
+		return config.Devices{}, fmt.Errorf("config error: %w", err)
+	}
+	return cfg.Devices, nil
+}
+
+// listDevices prints every input device node and whether it is listened to
+func (d *Daemon) listDevices() error {
+	devicesCfg, err := d.deviceConfig()
+	if err != nil {
+		return err
+	}
+	filter := listener.NewFilter(devicesCfg)
+
+	devices, err := listener.ListDevices(d.config.InputDir)
+	if err != nil {
+		return err
+	}
+
+	if len(devices) == 0 {
+		fmt.Printf("No input devices found in %s\n", d.config.InputDir)
+		return nil
+	}
+
+	for _, info := range devices {
+		writeDevice(os.Stdout, info, filter)
+	}
+
+	return nil
+}
+
+// writeDevice describes a device node and whether filter accepts it
+func writeDevice(w io.Writer, info listener.DeviceInfo, filter *listener.Filter) {
+	fmt.Fprintln(w, info.Path)
+	if info.Err != nil {
+		fmt.Fprintf(w, "  error:     %v\n\n", info.Err)
+		return
+	}
+
+	var types []string
+	for _, t := range info.Types {
+		types = append(types, evdev.TypeName(t))
+	}
+
+	fmt.Fprintf(w, "  name:      %s\n", info.Name)
+	fmt.Fprintf(w, "  id:        bus %04x vendor %04x product %04x version %04x\n",
+		info.ID.BusType, info.ID.Vendor, info.ID.Product, info.ID.Version)
+	if info.Phys != "" {
+		fmt.Fprintf(w, "  phys:      %s\n", info.Phys)
+	}
+	for _, link := range info.ByID {
+		fmt.Fprintf(w, "  by-id:     %s\n", link)
+	}
+	fmt.Fprintf(w, "  events:    %s\n", strings.Join(types, " "))
+	fmt.Fprintf(w, "  keys:      %d\n", info.Keys)
+	fmt.Fprintf(w, "  keyboard:  %s\n", yesNo(info.Keyboard))
+	fmt.Fprintf(w, "  listened:  %s\n\n", yesNo(filter.Accept(info)))
+}
+
+// watch prints key events live with the pressed set the matcher sees
+func (d *Daemon) watch() error {
+	devicesCfg, err := d.deviceConfig()
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	lst := listener.NewListener(d.config.InputDir, devicesCfg)
+	if err := lst.Start(ctx); err != nil {
+		return fmt.Errorf("listener error: %w", err)
+	}
+	defer lst.Stop()
+
+	fmt.Println("Watching key events, press Ctrl+C to stop")
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case ev, ok := <-lst.Events():
+			if !ok {
+				return nil
+			}
+			writeEvent(os.Stdout, ev)
+		}
+	}
+}
+
+// writeEvent prints a key event with the pressed set after it
+func writeEvent(w io.Writer, ev listener.Event) {
+	name, _ := hotkey.LookupKeyName(ev.Code)
+	fmt.Fprintf(w, "%s  %-24s code %-4d %-14s %-8s pressed [%s]\n",
+		ev.Time.Format("15:04:05.000"),
+		ev.Device,
+		ev.Code,
+		name,
+		valueName(ev.Value),
+		keyNames(ev.Snapshot.Keys),
+	)
+}
+
+// recordTrace writes raw events from the listened devices to a trace file
+// until interrupted
+func (d *Daemon) recordTrace() error {
+	devicesCfg, err := d.deviceConfig()
+	if err != nil {
+		return err
+	}
+
+	// Traces contain keystrokes, keep them private
+	out, err := os.OpenFile(d.config.TracePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("open trace: %w", err)
+	}
+	defer out.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	lst := listener.NewListener(d.config.InputDir, devicesCfg)
+	lst.Record(listener.NewTraceWriter(out, d.config.MaskTrace))
+	if err := lst.Start(ctx); err != nil {
+		return fmt.Errorf("listener error: %w", err)
+	}
+
+	fmt.Printf("Recording trace to %s, press Ctrl+C to stop\n", d.config.TracePath)
+	for {
+		select {
+		case <-ctx.Done():
+			lst.Stop()
+			fmt.Printf("Trace written to %s\n", d.config.TracePath)
+			return nil
+		case _, ok := <-lst.Events():
+			if !ok {
+				return nil
+			}
+		}
+	}
+}
+
+// showLogs prints the output of a binding's recent runs
+func (d *Daemon) showLogs() error {
+	runs, err := executor.RecentRuns(d.config.Binding, d.config.Runs)
+	if errors.Is(err, fs.ErrNotExist) {
+		fmt.Printf("No logs for %s (set 'output: log' on the binding)\n", d.config.Binding)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read logs: %w", err)
+	}
+
+	for _, run := range runs {
+		fmt.Print(run)
+	}
+	return nil
+}
+
+func valueName(value int32) string {
+	switch value {
+	case hotkey.KEY_PRESSED:
+		return "press"
+	case hotkey.KEY_RELEASED:
+		return "release"
+	case hotkey.KEY_REPEAT:
+		return "repeat"
+	default:
+		return fmt.Sprintf("%d", value)
+	}
+}
+
+func keyNames(codes []uint16) string {
+	names := make([]string, 0, len(codes))
+	for _, code := range codes {
+		name, found := hotkey.LookupKeyName(code)
+		if !found {
+			name = fmt.Sprintf("<%d>", code)
+		}
+		names = append(names, name)
+	}
+	return strings.Join(names, "+")
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
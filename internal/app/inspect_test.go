@@ -0,0 +1,109 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/glowfi/ghkd/internal/config"
+	"github.com/glowfi/ghkd/internal/listener"
+	"github.com/holoplot/go-evdev"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInspect_WriteEvent(t *testing.T) {
+	// Kernel timestamps are shown in local time
+	local := time.Local
+	time.Local = time.UTC
+	t.Cleanup(func() { time.Local = local })
+
+	file, err := os.Open("testdata/replay/ctrl_t.jsonl")
+	require.NoError(t, err, "expect trace to open")
+	defer file.Close()
+
+	replay := listener.NewReplay(file, config.Devices{}, false)
+	require.NoError(t, replay.Start(context.Background()), "expect replay to start")
+	defer replay.Stop()
+
+	var out bytes.Buffer
+	for ev := range replay.Events() {
+		writeEvent(&out, ev)
+	}
+
+	want := []string{
+		"22:13:20.000  /dev/input/event3        code 29   ctrl           press    pressed [ctrl]",
+		"22:13:20.050  /dev/input/event7        code 20   t              press    pressed [ctrl+t]",
+		"22:13:20.100  /dev/input/event7        code 20   t              release  pressed [ctrl]",
+		"22:13:20.150  /dev/input/event3        code 29   ctrl           release  pressed []",
+	}
+	assert.Equal(t, want, strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n"),
+		"expect one line per key event with the pressed keys after it")
+}
+
+func TestInspect_WriteDevice(t *testing.T) {
+	keyboard := listener.DeviceInfo{
+		Path:     "/dev/input/event3",
+		Name:     "AT Translated Set 2 keyboard",
+		Phys:     "isa0060/serio0/input0",
+		ID:       evdev.InputID{BusType: 0x11, Vendor: 0x1, Product: 0x1, Version: 0xab83},
+		ByID:     []string{"/dev/input/by-path/platform-i8042-serio-0-event-kbd"},
+		Types:    []evdev.EvType{evdev.EV_SYN, evdev.EV_KEY},
+		Keys:     113,
+		Keyboard: true,
+	}
+
+	tests := []struct {
+		name    string
+		info    listener.DeviceInfo
+		devices config.Devices
+		want    string
+	}{
+		{
+			name:    "should describe a listened keyboard :POS",
+			info:    keyboard,
+			devices: config.Devices{},
+			want: "/dev/input/event3\n" +
+				"  name:      AT Translated Set 2 keyboard\n" +
+				"  id:        bus 0011 vendor 0001 product 0001 version ab83\n" +
+				"  phys:      isa0060/serio0/input0\n" +
+				"  by-id:     /dev/input/by-path/platform-i8042-serio-0-event-kbd\n" +
+				"  events:    EV_SYN EV_KEY\n" +
+				"  keys:      113\n" +
+				"  keyboard:  yes\n" +
+				"  listened:  yes\n\n",
+		},
+		{
+			name:    "should show a keyboard the filter excludes as not listened :NEG",
+			info:    keyboard,
+			devices: config.Devices{Exclude: []config.DeviceRule{{Name: "^AT Translated"}}},
+			want: "/dev/input/event3\n" +
+				"  name:      AT Translated Set 2 keyboard\n" +
+				"  id:        bus 0011 vendor 0001 product 0001 version ab83\n" +
+				"  phys:      isa0060/serio0/input0\n" +
+				"  by-id:     /dev/input/by-path/platform-i8042-serio-0-event-kbd\n" +
+				"  events:    EV_SYN EV_KEY\n" +
+				"  keys:      113\n" +
+				"  keyboard:  yes\n" +
+				"  listened:  no\n\n",
+		},
+		{
+			name:    "should show why a node could not be read :NEG",
+			info:    listener.DeviceInfo{Path: "/dev/input/event9", Err: errors.New("permission denied")},
+			devices: config.Devices{},
+			want:    "/dev/input/event9\n  error:     permission denied\n\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			writeDevice(&out, tt.info, listener.NewFilter(tt.devices))
+			assert.Equal(t, tt.want, out.String(), "expect device description to match")
+		})
+	}
+}
@@ -14,48 +14,108 @@ const (
 	CommandKill
 	CommandReload
 	CommandBackground
+	CommandDevices
+	CommandWatch
+	CommandRecordTrace
+	CommandLogs
+	CommandSetEnv
+	CommandHistory
 )
 
+// subcommands are commands given as the first argument: "ghkd devices"
+var subcommands = map[string]Command{
+	"devices":      CommandDevices,
+	"watch":        CommandWatch,
+	"record-trace": CommandRecordTrace,
+	"logs":         CommandLogs,
+	"setenv":       CommandSetEnv,
+	"history":      CommandHistory,
+}
+
 type Options struct {
 	ConfigPath string
 	Command    Command
+	Args       []string // Positional arguments after flags
+	ReplayPath string   // Trace to replay instead of reading devices
+	TracePath  string   // Output of record-trace
+	MaskTrace  bool     // Hide typed letters and numbers in the recorded trace
+	Binding    string   // Binding whose logs or history are shown
+	Runs       int      // Number of runs shown by logs
+	SetEnv     []string // KEY=VALUE or KEY arguments of setenv
+	JSON       bool     // Print history as JSON
 }
 
+// Parse reads the options from the command line
 func Parse() (*Options, error) {
+	return parse(os.Args[1:])
+}
+
+func parse(args []string) (*Options, error) {
 	var (
 		configPath  string
 		background  bool
 		kill        bool
 		reload      bool
 		showVersion bool
+		replayPath  string
+		maskTrace   bool
+		runs        int
+		binding     string
+		jsonOutput  bool
 	)
 
+	// Exits on error, like flag.Parse
+	flags := flag.NewFlagSet("ghkd", flag.ExitOnError)
+
 	// Bind both short and long flags
-	flag.StringVar(&configPath, "c", "config.yaml", "config path")
-	flag.StringVar(&configPath, "config", "config.yaml", "config path")
+	flags.StringVar(&configPath, "c", "config.yaml", "config path")
+	flags.StringVar(&configPath, "config", "config.yaml", "config path")
+
+	flags.BoolVar(&background, "b", false, "background")
+	flags.BoolVar(&background, "background", false, "background")
+
+	flags.BoolVar(&kill, "k", false, "kill")
+	flags.BoolVar(&kill, "kill", false, "kill")
 
-	flag.BoolVar(&background, "b", false, "background")
-	flag.BoolVar(&background, "background", false, "background")
+	flags.BoolVar(&reload, "r", false, "reload")
+	flags.BoolVar(&reload, "reload", false, "reload")
 
-	flag.BoolVar(&kill, "k", false, "kill")
-	flag.BoolVar(&kill, "kill", false, "kill")
+	flags.BoolVar(&showVersion, "v", false, "version")
+	flags.BoolVar(&showVersion, "version", false, "version")
 
-	flag.BoolVar(&reload, "r", false, "reload")
-	flag.BoolVar(&reload, "reload", false, "reload")
+	flags.StringVar(&replayPath, "replay", "", "replay trace")
+	flags.BoolVar(&maskTrace, "mask", false, "mask typed keys in recorded trace")
+	flags.IntVar(&runs, "n", 5, "number of runs shown by logs")
+	flags.StringVar(&binding, "binding", "", "binding whose history is shown")
+	flags.BoolVar(&jsonOutput, "json", false, "print history as JSON")
 
-	flag.BoolVar(&showVersion, "v", false, "version")
-	flag.BoolVar(&showVersion, "version", false, "version")
+	flags.Usage = printUsage
 
-	flag.Usage = printUsage
-	flag.Parse()
+	subcommand, hasSubcommand := Command(0), false
+	if len(args) > 0 {
+		subcommand, hasSubcommand = subcommands[args[0]]
+		if hasSubcommand {
+			args = args[1:]
+		}
+	}
+
+	positional := parseInterspersed(flags, args)
 
 	opts := &Options{
 		ConfigPath: configPath,
 		Command:    CommandRun,
+		Args:       positional,
+		ReplayPath: replayPath,
+		MaskTrace:  maskTrace,
+		Runs:       runs,
+		Binding:    binding,
+		JSON:       jsonOutput,
 	}
 
 	// Determine command (priority order)
 	switch {
+	case hasSubcommand:
+		opts.Command = subcommand
 	case showVersion:
 		opts.Command = CommandVersion
 	case kill:
@@ -68,6 +128,31 @@ func Parse() (*Options, error) {
 		opts.Command = CommandRun
 	}
 
+	if opts.Command == CommandRecordTrace {
+		if len(opts.Args) != 1 {
+			return nil, fmt.Errorf("record-trace needs an output path (ghkd record-trace out.jsonl)")
+		}
+		opts.TracePath = opts.Args[0]
+	}
+
+	if opts.Command == CommandLogs {
+		if len(opts.Args) != 1 {
+			return nil, fmt.Errorf("logs needs a binding name (ghkd logs \"Open Alacritty\")")
+		}
+		opts.Binding = opts.Args[0]
+	}
+
+	if opts.Command == CommandHistory && len(opts.Args) > 0 {
+		return nil, fmt.Errorf("history takes no arguments (ghkd history --binding \"Open Alacritty\")")
+	}
+
+	if opts.Command == CommandSetEnv {
+		if len(opts.Args) == 0 {
+			return nil, fmt.Errorf("setenv needs variables (ghkd setenv WAYLAND_DISPLAY DISPLAY=:0)")
+		}
+		opts.SetEnv = opts.Args
+	}
+
 	// Validate config file exists for run commands
 	if opts.Command == CommandRun || opts.Command == CommandBackground {
 		if err := validateConfigPath(configPath); err != nil {
@@ -93,6 +178,15 @@ func printUsage() {
 
 Usage:
   ghkd [flags]
+  ghkd <command> [flags]
+
+Commands:
+  devices                  Lists input devices and whether they are listened to
+  watch                    Prints key events live as ghkd sees them
+  record-trace [path]      Records raw input events to a trace for bug reports
+  logs [binding]           Prints the output of the binding's recent runs
+  setenv [KEY[=VALUE]...]  Imports variables into the running daemon's actions
+  history                  Prints the recent runs of the running daemon
 
 Flags:
   -h,  --help              Prints this help message
@@ -101,9 +195,33 @@ Flags:
   -k,  --kill              Gracefully kills running instances
   -r,  --reload            Reloads configuration of running instance
   -v,  --version           Prints current version
+       --replay [path]     Replays a recorded trace instead of reading devices ("-" for stdin)
+       --mask              Records typed letters and numbers as KEY_RESERVED in record-trace
+  -n   [count]             Number of runs printed by logs (default 5)
+       --binding [name]    Prints only the history of this binding
+       --json              Prints the history as JSON
 `)
 }
 
+// parseInterspersed parses flags before, between and after positional
+// arguments, as in 'ghkd logs "Open Alacritty" -n 3', and returns the
+// positional ones. Everything after "--" is positional.
+func parseInterspersed(flags *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		_ = flags.Parse(args)
+		rest := flags.Args()
+		if parsed := len(args) - len(rest); parsed > 0 && args[parsed-1] == "--" {
+			return append(positional, rest...)
+		}
+		if len(rest) == 0 {
+			return positional
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
+
 // FilterBackgroundFlag returns args without the background flag
 func FilterBackgroundFlag(args []string) []string {
 	filtered := make([]string, 0, len(args))
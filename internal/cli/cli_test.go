@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCLI_Parse(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    Options
+		wantErr bool
+	}{
+		{
+			name: "should read flags after the binding of logs :POS",
+			args: []string{"logs", "Open Alacritty", "-n", "3"},
+			want: Options{Command: CommandLogs, Binding: "Open Alacritty", Runs: 3, Args: []string{"Open Alacritty"}},
+		},
+		{
+			name: "should read flags before the binding of logs :POS",
+			args: []string{"logs", "-n", "3", "Open Alacritty"},
+			want: Options{Command: CommandLogs, Binding: "Open Alacritty", Runs: 3, Args: []string{"Open Alacritty"}},
+		},
+		{
+			name: "should mask a trace with the flag after the path :POS",
+			args: []string{"record-trace", "out.jsonl", "--mask"},
+			want: Options{Command: CommandRecordTrace, TracePath: "out.jsonl", MaskTrace: true, Runs: 5, Args: []string{"out.jsonl"}},
+		},
+		{
+			name: "should read flags between setenv variables :POS",
+			args: []string{"setenv", "DISPLAY", "-c", "other.yaml", "WAYLAND_DISPLAY=wayland-1"},
+			want: Options{
+				Command: CommandSetEnv, ConfigPath: "other.yaml", Runs: 5,
+				Args:   []string{"DISPLAY", "WAYLAND_DISPLAY=wayland-1"},
+				SetEnv: []string{"DISPLAY", "WAYLAND_DISPLAY=wayland-1"},
+			},
+		},
+		{
+			name: "should take everything after -- as arguments :POS",
+			args: []string{"logs", "--", "-n"},
+			want: Options{Command: CommandLogs, Binding: "-n", Runs: 5, Args: []string{"-n"}},
+		},
+		{
+			name: "should read the history flags :POS",
+			args: []string{"history", "--json", "--binding", "Backup"},
+			want: Options{Command: CommandHistory, Binding: "Backup", JSON: true, Runs: 5},
+		},
+		{
+			name:    "should refuse logs with two bindings :NEG",
+			args:    []string{"logs", "A", "-n", "3", "B"},
+			wantErr: true,
+		},
+		{
+			name:    "should refuse history with an argument :NEG",
+			args:    []string{"history", "Backup", "--json"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		opts, err := parse(tt.args)
+		if tt.wantErr {
+			assert.Error(t, err, tt.name)
+			continue
+		}
+		require.NoError(t, err, tt.name)
+
+		if tt.want.ConfigPath == "" {
+			tt.want.ConfigPath = "config.yaml"
+		}
+		assert.Equal(t, tt.want, *opts, tt.name)
+	}
+}
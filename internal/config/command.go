@@ -0,0 +1,48 @@
+package config
+
+import "strings"
+
+// Command is a 'run' action. Written as a string it is a shell command
+// line, written as a list it is executed directly without a shell.
+type Command struct {
+	Line string   // Run with the shell: "notify-send 'Hello there'"
+	Argv []string // Executed directly: [notify-send, Hello there]
+}
+
+// IsZero reports whether the command is unset
+func (c Command) IsZero() bool {
+	return c.Line == "" && len(c.Argv) == 0
+}
+
+// String returns the command line, with the arguments of a list joined by
+// spaces
+func (c Command) String() string {
+	if len(c.Argv) > 0 {
+		return strings.Join(c.Argv, " ")
+	}
+	return c.Line
+}
+
+// UnmarshalYAML implements custom YAML unmarshaling
+func (c *Command) UnmarshalYAML(unmarshal func(any) error) error {
+	var line string
+	if err := unmarshal(&line); err == nil {
+		*c = Command{Line: line}
+		return nil
+	}
+
+	var argv []string
+	if err := unmarshal(&argv); err != nil {
+		return err
+	}
+	*c = Command{Argv: argv}
+	return nil
+}
+
+// MarshalYAML implements custom YAML marshaling
+func (c Command) MarshalYAML() (any, error) {
+	if len(c.Argv) > 0 {
+		return c.Argv, nil
+	}
+	return c.Line, nil
+}
@@ -3,7 +3,14 @@ package config
 import (
 	"errors"
 	"fmt"
+	"maps"
 	"os"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/glowfi/ghkd/internal/hotkey"
 	"github.com/goccy/go-yaml"
@@ -11,11 +18,116 @@ import (
 
 var (
 	ErrMissingKeybindingName   = errors.New("must provide a name to the keybinding")
-	ErrMultipleActions         = errors.New("only one of 'run', 'script', 'file' allowed")
-	ErrNoAction                = errors.New("must provide one of one of 'run', 'script', 'file'")
+	ErrMultipleActions         = errors.New("only one of 'run', 'script', 'file', 'actions', 'action' allowed")
+	ErrNoAction                = errors.New("must provide one of one of 'run', 'script', 'file', 'actions', 'action'")
 	ErrScriptNeedsInterpreter  = errors.New("'script' requires 'interpreter'")
 	ErrDuplicateKeybinding     = errors.New("duplicate keybinding found")
 	ErrDuplicateKeybindingName = errors.New("duplicate keybinding name found")
+	ErrMissingTrigger          = errors.New("must provide a trigger to the expansion")
+	ErrMissingExpansionText    = errors.New("must provide a text to the expansion")
+	ErrUntypeableCharacter     = errors.New("character cannot be typed on the keyboard layout")
+	ErrDuplicateTrigger        = errors.New("duplicate expansion trigger found")
+	ErrEmptyDeviceRule         = errors.New("device rule must set at least one field")
+	ErrInvalidDevicePattern    = errors.New("invalid device pattern")
+	ErrInvalidDeviceID         = errors.New("device vendor/product must be a hex ID")
+	ErrUnknownBus              = errors.New("unknown bus type")
+	ErrInvalidCrossDevice      = errors.New("cross_device must be one of 'merge', 'isolate'")
+	ErrInvalidConcurrency      = errors.New("concurrency must be one of 'parallel', 'single', 'restart', 'toggle', 'queue'")
+	ErrNegativeTimeout         = errors.New("timeout must not be negative")
+	ErrInvalidOutput           = errors.New("output must be one of 'discard', 'log', 'inherit'")
+	ErrInvalidEnvFile          = errors.New("env file lines must be KEY=VALUE")
+	ErrUnknownUser             = errors.New("unknown user")
+	ErrNestedParallel          = errors.New("'parallel' steps cannot contain 'parallel' or 'continue_on_error'")
+	ErrUnknownAction           = errors.New("unknown built-in action")
+	ErrActionArgument          = errors.New("wrong argument for built-in action")
+	ErrUnknownBinding          = errors.New("no keybinding with that name")
+	ErrUnknownMode             = errors.New("no keybinding uses that mode")
+	ErrEmptyCondition          = errors.New("'when' must set at least one condition")
+	ErrInvalidTimeWindow       = errors.New("time window must be 'HH:MM-HH:MM'")
+	ErrModeConflict            = errors.New("'mode' and 'when.mode' differ")
+	ErrNegativeCooldown        = errors.New("cooldown must not be negative")
+	ErrNegativeDebounce        = errors.New("debounce must not be negative")
+	ErrInvalidSpawnLimit       = errors.New("max_spawns_per_second must not be negative")
+	ErrArgsNeedFile            = errors.New("'args' requires 'file'")
+	ErrEmptyArgument           = errors.New("'run' list must start with a program")
+	ErrInvalidOn               = errors.New("on must be one of 'press', 'release', 'hold'")
+)
+
+// Cross-device policies for keys held on different devices
+const (
+	CrossDeviceMerge   = "merge"   // Keys held on any device form one combo
+	CrossDeviceIsolate = "isolate" // Combos only match keys held on one device
+)
+
+// Concurrency policies for a binding triggered while its action still runs
+const (
+	ConcurrencyParallel = "parallel" // Start another copy
+	ConcurrencySingle   = "single"   // Ignore the trigger
+	ConcurrencyRestart  = "restart"  // Stop the running copy and start again
+	ConcurrencyToggle   = "toggle"   // Stop the running copy
+	ConcurrencyQueue    = "queue"    // Start once the running copy exits
+)
+
+// Key events a binding fires on
+const (
+	OnPress   = "press"   // The combo is completed
+	OnRelease = "release" // A key of the combo is released
+	OnHold    = "hold"    // Each auto-repeat while the combo is held
+)
+
+// DefaultMode is the mode of bindings that don't set one, and the mode the
+// daemon starts in
+const DefaultMode = "default"
+
+// Built-in actions handled by the daemon itself
+const (
+	ActionReload      = "reload"       // Reload the config
+	ActionQuit        = "quit"         // Stop the daemon
+	ActionPause       = "pause"        // Ignore every binding except resume and toggle-pause
+	ActionResume      = "resume"       // Undo pause
+	ActionTogglePause = "toggle-pause" // Pause or resume
+	ActionMode        = "mode"         // mode <name>: switch to a mode
+	ActionEnable      = "enable"       // enable <binding>
+	ActionDisable     = "disable"      // disable <binding>
+	ActionKill        = "kill"         // kill <binding>: stop its running actions
+	ActionLog         = "log"          // log <message>
+)
+
+// actionTakesArgument tells whether each built-in action needs an argument
+var actionTakesArgument = map[string]bool{
+	ActionReload:      false,
+	ActionQuit:        false,
+	ActionPause:       false,
+	ActionResume:      false,
+	ActionTogglePause: false,
+	ActionMode:        true,
+	ActionEnable:      true,
+	ActionDisable:     true,
+	ActionKill:        true,
+	ActionLog:         true,
+}
+
+// ParseAction splits a built-in action into its verb and argument:
+// "mode resize" is ("mode", "resize")
+func ParseAction(action string) (verb, arg string, err error) {
+	verb, arg, _ = strings.Cut(strings.TrimSpace(action), " ")
+	arg = strings.TrimSpace(arg)
+
+	takesArg, known := actionTakesArgument[verb]
+	if !known {
+		return "", "", fmt.Errorf("%s: %w", verb, ErrUnknownAction)
+	}
+	if takesArg != (arg != "") {
+		return "", "", fmt.Errorf("%s: %w", action, ErrActionArgument)
+	}
+	return verb, arg, nil
+}
+
+// Output destinations for the stdout and stderr of an action
+const (
+	OutputDiscard = "discard" // Thrown away
+	OutputLog     = "log"     // Appended to the binding's log file
+	OutputInherit = "inherit" // Written to the daemon's stdout and stderr
 )
 
 type Keybinding struct {
@@ -24,16 +136,155 @@ type Keybinding struct {
 	KeyCombination hotkey.KeyCombo `yaml:"keys"`
 
 	// Action - one of these must be set
-	File string `yaml:"file,omitempty"` // External script: "~/script.sh"
+	File string   `yaml:"file,omitempty"` // External script: "~/script.sh"
+	Args []string `yaml:"args,omitempty"` // Arguments for 'file': [--full, "$HOME"]
 
-	Run string `yaml:"run,omitempty"` // Simple command: "alacritty"
+	Run Command `yaml:"run,omitempty"` // Simple command: "alacritty", or argv without a shell: [alacritty, -e, htop]
 
 	Interpreter string `yaml:"interpreter,omitempty"` // Script interpreter: "python3,node,bash"
 	Script      string `yaml:"script,omitempty"`      // Script content
+
+	Actions []Step `yaml:"actions,omitempty"` // Steps run in order
+	Action  string `yaml:"action,omitempty"`  // Built-in action: "toggle-pause", "mode resize"
+
+	Mode string `yaml:"mode,omitempty"` // Only active in this mode, "default" if empty
+	On   string `yaml:"on,omitempty"`   // Fire on: "press,release,hold", "press" if empty
+
+	// Conditions - bindings with the same keys are tried by priority
+	When     *When `yaml:"when,omitempty"`     // Only fire if this holds: "pgrep -x steam"
+	Priority int   `yaml:"priority,omitempty"` // Higher is tried first, then config order
+
+	// Execution
+	Concurrency string        `yaml:"concurrency,omitempty"` // While running: "parallel,single,restart,toggle,queue"
+	Timeout     time.Duration `yaml:"timeout,omitempty"`     // Stop the action after: "30s"
+	Output      string        `yaml:"output,omitempty"`      // Action output: "discard,log,inherit"
+	Cooldown    time.Duration `yaml:"cooldown,omitempty"`    // Ignore triggers this soon after a run started: "2s"
+	Debounce    time.Duration `yaml:"debounce,omitempty"`    // Ignore triggers this soon after the previous one: "300ms"
+
+	// Hooks - run like 'run' with the binding's settings after the action
+	OnSuccess Command `yaml:"on_success,omitempty"` // After it exits 0
+	OnFailure Command `yaml:"on_failure,omitempty"` // After it fails or times out: "notify-send 'Backup failed'"
+
+	// Environment - merged over the defaults
+	Env     map[string]string `yaml:"env,omitempty"`      // Extra variables: {EDITOR: nvim}
+	EnvFile string            `yaml:"env_file,omitempty"` // Dotenv file, relative to the config
+	Cwd     string            `yaml:"cwd,omitempty"`      // Working directory: "$HOME/notes"
+	Shell   string            `yaml:"shell,omitempty"`    // Shell for 'run': "bash,zsh,fish"
+	User    string            `yaml:"user,omitempty"`     // Run as this user when the daemon is root
+}
+
+// Step is one step of an 'actions' pipeline. It runs one of 'run',
+// 'script', 'file', or all 'parallel' steps at once.
+type Step struct {
+	File        string   `yaml:"file,omitempty"`
+	Args        []string `yaml:"args,omitempty"`
+	Run         Command  `yaml:"run,omitempty"`
+	Interpreter string   `yaml:"interpreter,omitempty"`
+	Script      string   `yaml:"script,omitempty"`
+	Parallel    []Step   `yaml:"parallel,omitempty"`
+
+	ContinueOnError bool `yaml:"continue_on_error,omitempty"` // Run the next step even if this one fails
+}
+
+// ModeName returns the mode the binding is active in
+func (kb Keybinding) ModeName() string {
+	switch {
+	case kb.Mode != "":
+		return kb.Mode
+	case kb.When != nil && kb.When.Mode != "":
+		return kb.When.Mode
+	default:
+		return DefaultMode
+	}
+}
+
+// Event returns the key event the binding fires on
+func (kb Keybinding) Event() string {
+	if kb.On == "" {
+		return OnPress
+	}
+	return kb.On
+}
+
+// Steps returns the binding's action as pipeline steps
+func (kb Keybinding) Steps() []Step {
+	if len(kb.Actions) > 0 {
+		return kb.Actions
+	}
+	return []Step{{File: kb.File, Args: kb.Args, Run: kb.Run, Interpreter: kb.Interpreter, Script: kb.Script}}
+}
+
+// Defaults are execution settings for bindings that don't set their own
+type Defaults struct {
+	Timeout time.Duration     `yaml:"timeout,omitempty"`
+	Output  string            `yaml:"output,omitempty"`
+	Env     map[string]string `yaml:"env,omitempty"`
+	EnvFile string            `yaml:"env_file,omitempty"`
+	Cwd     string            `yaml:"cwd,omitempty"`
+	Shell   string            `yaml:"shell,omitempty"`
+	User    string            `yaml:"user,omitempty"`
+}
+
+// Expansion replaces a typed abbreviation with a longer text
+type Expansion struct {
+	Trigger string `yaml:"trigger"` // Typed abbreviation: ";sig"
+	Text    string `yaml:"text"`    // Replacement text
+}
+
+// DeviceRule selects input devices. Every field that is set must match.
+type DeviceRule struct {
+	Name    string    `yaml:"name,omitempty"`    // Regex on the device name: "YubiKey"
+	Vendor  *DeviceID `yaml:"vendor,omitempty"`  // Hex vendor ID: 1050
+	Product *DeviceID `yaml:"product,omitempty"` // Hex product ID: 0407
+	Bus     string    `yaml:"bus,omitempty"`     // Bus type: "usb,bluetooth,i8042,virtual"
+	Phys    string    `yaml:"phys,omitempty"`    // Regex on the physical location
+	ID      string    `yaml:"id,omitempty"`      // Name of a /dev/input/by-id symlink
+	Path    string    `yaml:"path,omitempty"`    // Device node path: "/dev/input/event3"
+}
+
+// Devices decides which input devices are listened to and how their keys combine
+type Devices struct {
+	Include     []DeviceRule `yaml:"include,omitempty"`      // Listen even if not detected as a keyboard
+	Exclude     []DeviceRule `yaml:"exclude,omitempty"`      // Never listen
+	CrossDevice string       `yaml:"cross_device,omitempty"` // Cross-device combos: "merge,isolate"
+}
+
+// ImportEnv refreshes session variables, like WAYLAND_DISPLAY, before each
+// action from a file or a running process. Variables sent with
+// 'ghkd setenv' are imported as well.
+type ImportEnv struct {
+	Vars    []string `yaml:"vars,omitempty"`    // Variables to import, DefaultImportVars if empty
+	File    string   `yaml:"file,omitempty"`    // Dotenv file written by the session: "~/.cache/session.env"
+	Process string   `yaml:"process,omitempty"` // Name of a session process to read from: "sway"
+	User    string   `yaml:"user,omitempty"`    // Owner of the process, the user each action runs as if empty
+}
+
+// DefaultImportVars are the variables imported when ImportEnv.Vars is empty
+var DefaultImportVars = []string{
+	"WAYLAND_DISPLAY",
+	"DISPLAY",
+	"XAUTHORITY",
+	"DBUS_SESSION_BUS_ADDRESS",
+	"XDG_CURRENT_DESKTOP",
+	"XDG_SESSION_TYPE",
+}
+
+// DefaultMaxSpawnsPerSecond is the spawn limit when Limits doesn't set one
+const DefaultMaxSpawnsPerSecond = 20
+
+// Limits protect the system from runaway triggers
+type Limits struct {
+	MaxSpawnsPerSecond int `yaml:"max_spawns_per_second,omitempty"` // Actions started per second, all bindings together. 0 uses the default.
 }
 
 type Config struct {
+	Defaults    Defaults     `yaml:"defaults,omitempty"`
+	Limits      Limits       `yaml:"limits,omitempty"`
+	OnError     Command      `yaml:"on_error,omitempty"` // Run after any binding fails
+	ImportEnv   ImportEnv    `yaml:"import_env,omitempty"`
 	Keybindings []Keybinding `yaml:"keybindings"`
+	Expansions  []Expansion  `yaml:"expansions,omitempty"`
+	Devices     Devices      `yaml:"devices,omitempty"`
 }
 
 func LoadConfig(path string) (Config, error) {
@@ -47,10 +298,42 @@ func LoadConfig(path string) (Config, error) {
 		return Config{}, err
 	}
 
+	if cfg.Defaults.Timeout < 0 {
+		return Config{}, fmt.Errorf("defaults: %w", ErrNegativeTimeout)
+	}
+
+	if err := validateOutput(cfg.Defaults.Output); err != nil {
+		return Config{}, fmt.Errorf("defaults: %w", err)
+	}
+
+	if err := validateUser(cfg.Defaults.User); err != nil {
+		return Config{}, fmt.Errorf("defaults: %w", err)
+	}
+
+	if err := validateUser(cfg.ImportEnv.User); err != nil {
+		return Config{}, fmt.Errorf("import_env: %w", err)
+	}
+
+	if cfg.Limits.MaxSpawnsPerSecond < 0 {
+		return Config{}, fmt.Errorf("limits: %w", ErrInvalidSpawnLimit)
+	}
+
+	if err := validateCommand(Step{Run: cfg.OnError}); err != nil {
+		return Config{}, fmt.Errorf("on_error: %w", err)
+	}
+
+	// Env files are relative to the config file
+	baseDir := filepath.Dir(path)
+
+	defaultEnv, err := mergeEnv(cfg.Defaults.EnvFile, cfg.Defaults.Env, baseDir)
+	if err != nil {
+		return Config{}, fmt.Errorf("defaults: env_file: %w", err)
+	}
+
 	seenKeybindings := map[string]bool{}
 	seenKeybindingsName := map[string]bool{}
 
-	for _, kb := range cfg.Keybindings {
+	for i, kb := range cfg.Keybindings {
 		if kb.Name == "" {
 			return Config{}, ErrMissingKeybindingName
 		}
@@ -67,8 +350,65 @@ func LoadConfig(path string) (Config, error) {
 			return Config{}, fmt.Errorf("%s: %w", kb.Name, ErrScriptNeedsInterpreter)
 		}
 
-		_, KeyBindingexists := seenKeybindings[kb.KeyCombination.Raw]
-		if KeyBindingexists {
+		if err := validateSteps(kb.Actions, false); err != nil {
+			return Config{}, fmt.Errorf("%s: %w", kb.Name, err)
+		}
+
+		if err := validateCommand(Step{File: kb.File, Args: kb.Args, Run: kb.Run}); err != nil {
+			return Config{}, fmt.Errorf("%s: %w", kb.Name, err)
+		}
+
+		if err := validateCommand(Step{Run: kb.OnSuccess}); err != nil {
+			return Config{}, fmt.Errorf("%s: on_success: %w", kb.Name, err)
+		}
+
+		if err := validateCommand(Step{Run: kb.OnFailure}); err != nil {
+			return Config{}, fmt.Errorf("%s: on_failure: %w", kb.Name, err)
+		}
+
+		switch kb.Concurrency {
+		case "", ConcurrencyParallel, ConcurrencySingle, ConcurrencyRestart, ConcurrencyToggle, ConcurrencyQueue:
+		default:
+			return Config{}, fmt.Errorf("%s: %w", kb.Name, ErrInvalidConcurrency)
+		}
+
+		if kb.Timeout < 0 {
+			return Config{}, fmt.Errorf("%s: %w", kb.Name, ErrNegativeTimeout)
+		}
+
+		if err := validateOutput(kb.Output); err != nil {
+			return Config{}, fmt.Errorf("%s: %w", kb.Name, err)
+		}
+
+		switch kb.On {
+		case "", OnPress, OnRelease, OnHold:
+		default:
+			return Config{}, fmt.Errorf("%s: %w", kb.Name, ErrInvalidOn)
+		}
+
+		if kb.Cooldown < 0 {
+			return Config{}, fmt.Errorf("%s: %w", kb.Name, ErrNegativeCooldown)
+		}
+
+		if kb.Debounce < 0 {
+			return Config{}, fmt.Errorf("%s: %w", kb.Name, ErrNegativeDebounce)
+		}
+
+		if kb.Action != "" {
+			if _, _, err := ParseAction(kb.Action); err != nil {
+				return Config{}, fmt.Errorf("%s: %w", kb.Name, err)
+			}
+		}
+
+		if err := validateWhen(kb); err != nil {
+			return Config{}, fmt.Errorf("%s: when: %w", kb.Name, err)
+		}
+
+		// The same keys may be bound in different modes and for different
+		// events, and by any number of guarded bindings besides one fallback
+		comboKey := kb.ModeName() + " " + kb.Event() + " " + kb.KeyCombination.Raw
+		_, KeyBindingexists := seenKeybindings[comboKey]
+		if KeyBindingexists && !kb.When.Guarded() {
 			return Config{}, fmt.Errorf("%s: %w", kb.Name, ErrDuplicateKeybinding)
 		}
 
@@ -78,15 +418,136 @@ func LoadConfig(path string) (Config, error) {
 		}
 
 		seenKeybindingsName[kb.Name] = true
-		seenKeybindings[kb.KeyCombination.Raw] = true
+		if !kb.When.Guarded() {
+			seenKeybindings[comboKey] = true
+		}
+
+		if err := validateUser(kb.User); err != nil {
+			return Config{}, fmt.Errorf("%s: %w", kb.Name, err)
+		}
+
+		kb.Env, err = mergeEnv(kb.EnvFile, kb.Env, baseDir)
+		if err != nil {
+			return Config{}, fmt.Errorf("%s: env_file: %w", kb.Name, err)
+		}
+
+		cfg.Keybindings[i] = applyDefaults(kb, cfg.Defaults, defaultEnv)
+	}
+
+	if err := validateActionTargets(cfg.Keybindings); err != nil {
+		return Config{}, err
+	}
+
+	if err := validateExpansions(cfg.Expansions); err != nil {
+		return Config{}, err
+	}
+
+	if err := validateDevices(cfg.Devices); err != nil {
+		return Config{}, err
 	}
 
 	return cfg, nil
 }
 
+// applyDefaults fills the settings a binding leaves unset from the defaults.
+// The binding's variables are merged over defaultEnv.
+func applyDefaults(kb Keybinding, defaults Defaults, defaultEnv map[string]string) Keybinding {
+	if kb.Timeout == 0 {
+		kb.Timeout = defaults.Timeout
+	}
+	if kb.Output == "" {
+		kb.Output = defaults.Output
+	}
+	if kb.Cwd == "" {
+		kb.Cwd = defaults.Cwd
+	}
+	if kb.Shell == "" {
+		kb.Shell = defaults.Shell
+	}
+	if kb.User == "" {
+		kb.User = defaults.User
+	}
+
+	if len(defaultEnv) > 0 {
+		env := maps.Clone(defaultEnv)
+		maps.Copy(env, kb.Env)
+		kb.Env = env
+	}
+	return kb
+}
+
+// LookupUser finds a user by name or numeric ID
+func LookupUser(name string) (*user.User, error) {
+	u, err := user.Lookup(name)
+	if err == nil {
+		return u, nil
+	}
+	if _, convErr := strconv.Atoi(name); convErr == nil {
+		if u, err := user.LookupId(name); err == nil {
+			return u, nil
+		}
+	}
+	return nil, fmt.Errorf("%s: %w", name, ErrUnknownUser)
+}
+
+func validateUser(name string) error {
+	if name == "" {
+		return nil
+	}
+	_, err := LookupUser(name)
+	return err
+}
+
+func validateOutput(output string) error {
+	switch output {
+	case "", OutputDiscard, OutputLog, OutputInherit:
+		return nil
+	default:
+		return fmt.Errorf("%s: %w", output, ErrInvalidOutput)
+	}
+}
+
+func validateExpansions(expansions []Expansion) error {
+	seenTriggers := map[string]bool{}
+
+	for _, exp := range expansions {
+		if exp.Trigger == "" {
+			return ErrMissingTrigger
+		}
+
+		if exp.Text == "" {
+			return fmt.Errorf("%s: %w", exp.Trigger, ErrMissingExpansionText)
+		}
+
+		if err := checkTypeable(exp.Trigger); err != nil {
+			return fmt.Errorf("%s: trigger: %w", exp.Trigger, err)
+		}
+
+		if err := checkTypeable(exp.Text); err != nil {
+			return fmt.Errorf("%s: text: %w", exp.Trigger, err)
+		}
+
+		if seenTriggers[exp.Trigger] {
+			return fmt.Errorf("%s: %w", exp.Trigger, ErrDuplicateTrigger)
+		}
+		seenTriggers[exp.Trigger] = true
+	}
+
+	return nil
+}
+
+func checkTypeable(s string) error {
+	for _, char := range s {
+		if _, ok := hotkey.LookupKeyStroke(char); !ok {
+			return fmt.Errorf("%q: %w", char, ErrUntypeableCharacter)
+		}
+	}
+	return nil
+}
+
 func countActions(kb Keybinding) int {
 	count := 0
-	if kb.Run != "" {
+	if !kb.Run.IsZero() {
 		count++
 	}
 	if kb.Script != "" {
@@ -95,5 +556,154 @@ func countActions(kb Keybinding) int {
 	if kb.File != "" {
 		count++
 	}
+	if len(kb.Actions) > 0 {
+		count++
+	}
+	if kb.Action != "" {
+		count++
+	}
 	return count
 }
+
+// validateActionTargets checks that built-in actions name existing
+// bindings and modes
+func validateActionTargets(bindings []Keybinding) error {
+	names := map[string]bool{}
+	modes := map[string]bool{DefaultMode: true}
+	for _, kb := range bindings {
+		names[kb.Name] = true
+		modes[kb.ModeName()] = true
+	}
+
+	for _, kb := range bindings {
+		if kb.Action == "" {
+			continue
+		}
+		verb, arg, _ := ParseAction(kb.Action)
+		switch verb {
+		case ActionEnable, ActionDisable, ActionKill:
+			if !names[arg] {
+				return fmt.Errorf("%s: %s: %w", kb.Name, arg, ErrUnknownBinding)
+			}
+		case ActionMode:
+			if !modes[arg] {
+				return fmt.Errorf("%s: %s: %w", kb.Name, arg, ErrUnknownMode)
+			}
+		}
+	}
+	return nil
+}
+
+// validateCommand checks the arguments of a 'run' list or a 'file'
+func validateCommand(step Step) error {
+	if len(step.Args) > 0 && step.File == "" {
+		return ErrArgsNeedFile
+	}
+	if len(step.Run.Argv) > 0 && step.Run.Argv[0] == "" {
+		return ErrEmptyArgument
+	}
+	return nil
+}
+
+// validateSteps checks that every step has exactly one action. Members of
+// a parallel group are plain steps.
+func validateSteps(steps []Step, inParallel bool) error {
+	for i, step := range steps {
+		if inParallel && (len(step.Parallel) > 0 || step.ContinueOnError) {
+			return fmt.Errorf("step %d: %w", i+1, ErrNestedParallel)
+		}
+
+		count := 0
+		for _, set := range []bool{!step.Run.IsZero(), step.Script != "", step.File != "", len(step.Parallel) > 0} {
+			if set {
+				count++
+			}
+		}
+		switch {
+		case count == 0:
+			return fmt.Errorf("step %d: %w", i+1, ErrNoAction)
+		case count > 1:
+			return fmt.Errorf("step %d: %w", i+1, ErrMultipleActions)
+		case step.Script != "" && step.Interpreter == "":
+			return fmt.Errorf("step %d: %w", i+1, ErrScriptNeedsInterpreter)
+		}
+
+		if err := validateCommand(step); err != nil {
+			return fmt.Errorf("step %d: %w", i+1, err)
+		}
+
+		if err := validateSteps(step.Parallel, true); err != nil {
+			return fmt.Errorf("step %d: parallel: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// BusTypes maps bus names to the kernel's BUS_* values
+var BusTypes = map[string]uint16{
+	"pci":       0x01,
+	"usb":       0x03,
+	"bluetooth": 0x05,
+	"virtual":   0x06,
+	"isa":       0x10,
+	"i8042":     0x11,
+	"rs232":     0x13,
+	"gameport":  0x14,
+	"i2c":       0x18,
+	"host":      0x19,
+	"spi":       0x1C,
+}
+
+func validateDevices(devices Devices) error {
+	switch devices.CrossDevice {
+	case "", CrossDeviceMerge, CrossDeviceIsolate:
+	default:
+		return fmt.Errorf("%s: %w", devices.CrossDevice, ErrInvalidCrossDevice)
+	}
+
+	for _, rule := range append(devices.Include, devices.Exclude...) {
+		if rule == (DeviceRule{}) {
+			return ErrEmptyDeviceRule
+		}
+
+		for _, pattern := range []string{rule.Name, rule.Phys} {
+			if _, err := regexp.Compile(pattern); err != nil {
+				return fmt.Errorf("%s: %w", pattern, ErrInvalidDevicePattern)
+			}
+		}
+
+		if _, ok := BusTypes[strings.ToLower(rule.Bus)]; rule.Bus != "" && !ok {
+			return fmt.Errorf("%s: %w", rule.Bus, ErrUnknownBus)
+		}
+	}
+
+	return nil
+}
+
+// DeviceID is a vendor or product ID. It is always written in hex, so
+// 1050 and 0x1050 are the same ID.
+type DeviceID uint16
+
+// UnmarshalYAML implements custom YAML unmarshaling. The raw text is parsed
+// so YAML doesn't read the ID as a decimal or octal number first.
+func (id *DeviceID) UnmarshalYAML(data []byte) error {
+	raw := strings.Trim(strings.TrimSpace(string(data)), `"'`)
+	hex := strings.TrimPrefix(strings.ToLower(raw), "0x")
+
+	value, err := strconv.ParseUint(hex, 16, 16)
+	if err != nil {
+		return fmt.Errorf("%s: %w", raw, ErrInvalidDeviceID)
+	}
+
+	*id = DeviceID(value)
+	return nil
+}
+
+// MarshalYAML implements custom YAML marshaling
+func (id DeviceID) MarshalYAML() (any, error) {
+	return id.String(), nil
+}
+
+func (id DeviceID) String() string {
+	return fmt.Sprintf("%04x", uint16(id))
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"testing"
+	"time"
 
 	"github.com/glowfi/ghkd/internal/hotkey"
 	"github.com/goccy/go-yaml"
@@ -17,6 +18,11 @@ func loadTestConfigYAML(t *testing.T, path string) []byte {
 	return data
 }
 
+func deviceID(id uint16) *DeviceID {
+	d := DeviceID(id)
+	return &d
+}
+
 func TestConfigMarshalUnmarshal(t *testing.T) {
 	tests := []struct {
 		name         string
@@ -41,7 +47,7 @@ func TestConfigMarshalUnmarshal(t *testing.T) {
 							Key:       hotkey.KEY_T,
 							Raw:       "ctrl+alt+t",
 						},
-						Run: "alacritty",
+						Run: Command{Line: "alacritty"},
 					},
 				},
 			},
@@ -97,7 +103,7 @@ subprocess.run(["notify-send", "Memory Usage", mem])
 							Key:       hotkey.KEY_T,
 							Raw:       "ctrl+alt+t",
 						},
-						Run: "alacritty",
+						Run: Command{Line: "alacritty"},
 					},
 					{
 						Name: "System Info",
@@ -144,6 +150,94 @@ subprocess.run(["notify-send", "Memory Usage", mem])
 	}
 }
 
+func TestConfig_ExpandPath(t *testing.T) {
+	home, _ := os.UserHomeDir()
+	t.Setenv("GHKD_TEST_DIR", "/srv")
+
+	tests := []struct {
+		name     string
+		path     string
+		env      map[string]string
+		wantPath string
+	}{
+		{
+			name:     "should expand home prefix :POS",
+			path:     "~/scripts/backup.sh",
+			wantPath: home + "/scripts/backup.sh",
+		},
+		{
+			name:     "should expand daemon variables :POS",
+			path:     "$GHKD_TEST_DIR/backup.sh",
+			wantPath: "/srv/backup.sh",
+		},
+		{
+			name:     "should prefer binding variables :POS",
+			path:     "${GHKD_TEST_DIR}/backup.sh",
+			env:      map[string]string{"GHKD_TEST_DIR": "/opt"},
+			wantPath: "/opt/backup.sh",
+		},
+		{
+			name:     "should expand home prefix from binding variables :POS",
+			path:     "~/scripts/backup.sh",
+			env:      map[string]string{"HOME": "/home/jane"},
+			wantPath: "/home/jane/scripts/backup.sh",
+		},
+		{
+			name:     "should keep home prefix in the middle :NEG",
+			path:     "/tmp/~/backup.sh",
+			wantPath: "/tmp/~/backup.sh",
+		},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.wantPath, ExpandPath(tt.path, tt.env), tt.name)
+	}
+}
+
+func TestConfig_ParseTimeWindow(t *testing.T) {
+	tests := []struct {
+		name      string
+		window    string
+		wantStart time.Duration
+		wantEnd   time.Duration
+		wantErr   error
+	}{
+		{
+			name:      "should parse daytime window :POS",
+			window:    "09:00-17:30",
+			wantStart: 9 * time.Hour,
+			wantEnd:   17*time.Hour + 30*time.Minute,
+		},
+		{
+			name:      "should parse window past midnight :POS",
+			window:    "22:00 - 06:00",
+			wantStart: 22 * time.Hour,
+			wantEnd:   6 * time.Hour,
+		},
+		{
+			name:    "should return error without separator :NEG",
+			window:  "09:00",
+			wantErr: ErrInvalidTimeWindow,
+		},
+		{
+			name:    "should return error for invalid clock :NEG",
+			window:  "9am-5pm",
+			wantErr: ErrInvalidTimeWindow,
+		},
+	}
+
+	for _, tt := range tests {
+		start, end, err := ParseTimeWindow(tt.window)
+		if tt.wantErr != nil {
+			assert.ErrorIs(t, err, tt.wantErr, tt.name)
+			continue
+		}
+		assert.NoError(t, err, tt.name)
+		assert.Equal(t, tt.wantStart, start, tt.name)
+		assert.Equal(t, tt.wantEnd, end, tt.name)
+	}
+}
+
 func TestConfig_LoadConfig(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -199,6 +293,613 @@ func TestConfig_LoadConfig(t *testing.T) {
 			expectedConfig: Config{},
 			wantErr:        ErrNoAction,
 		},
+		{
+			name:           "should return error when expansion has no trigger :NEG",
+			configPath:     "./testdata/load_config/expansion_no_trigger.yaml",
+			expectedConfig: Config{},
+			wantErr:        ErrMissingTrigger,
+		},
+		{
+			name:           "should return error when expansion text cannot be typed :NEG",
+			configPath:     "./testdata/load_config/expansion_untypeable.yaml",
+			expectedConfig: Config{},
+			wantErr:        ErrUntypeableCharacter,
+		},
+		{
+			name:           "should return error when expansion triggers are duplicated :NEG",
+			configPath:     "./testdata/load_config/expansion_duplicate_trigger.yaml",
+			expectedConfig: Config{},
+			wantErr:        ErrDuplicateTrigger,
+		},
+		{
+			name:       "should successfully load expansions :POS",
+			configPath: "./testdata/load_config/valid_expansions.yaml",
+			expectedConfig: Config{
+				Keybindings: []Keybinding{
+					{
+						Name: "Open Alacritty",
+						KeyCombination: hotkey.KeyCombo{
+							Modifiers: []uint16{hotkey.KEY_LEFTCTRL, hotkey.KEY_LEFTALT},
+							Key:       hotkey.KEY_T,
+							Raw:       "ctrl+alt+t",
+						},
+						Run: Command{Line: "alacritty"},
+					},
+				},
+				Expansions: []Expansion{
+					{Trigger: ";sig", Text: "Best regards,\nJane"},
+					{Trigger: ";mail", Text: "jane@example.com"},
+				},
+			},
+			wantErr: nil,
+		},
+		{
+			name:           "should return error when device name pattern is invalid :NEG",
+			configPath:     "./testdata/load_config/devices_invalid_pattern.yaml",
+			expectedConfig: Config{},
+			wantErr:        ErrInvalidDevicePattern,
+		},
+		{
+			name:           "should return error when device bus type is unknown :NEG",
+			configPath:     "./testdata/load_config/devices_unknown_bus.yaml",
+			expectedConfig: Config{},
+			wantErr:        ErrUnknownBus,
+		},
+		{
+			name:           "should return error when device rule is empty :NEG",
+			configPath:     "./testdata/load_config/devices_empty_rule.yaml",
+			expectedConfig: Config{},
+			wantErr:        ErrEmptyDeviceRule,
+		},
+		{
+			name:           "should return error when cross device policy is unknown :NEG",
+			configPath:     "./testdata/load_config/devices_invalid_cross_device.yaml",
+			expectedConfig: Config{},
+			wantErr:        ErrInvalidCrossDevice,
+		},
+		{
+			name:       "should successfully load device rules :POS",
+			configPath: "./testdata/load_config/valid_devices.yaml",
+			expectedConfig: Config{
+				Keybindings: []Keybinding{
+					{
+						Name: "Open Alacritty",
+						KeyCombination: hotkey.KeyCombo{
+							Modifiers: []uint16{hotkey.KEY_LEFTCTRL, hotkey.KEY_LEFTALT},
+							Key:       hotkey.KEY_T,
+							Raw:       "ctrl+alt+t",
+						},
+						Run: Command{Line: "alacritty"},
+					},
+				},
+				Devices: Devices{
+					Include: []DeviceRule{
+						{Path: "/dev/input/by-id/usb-Keypad-event-kbd"},
+						{Name: "^Numeric Keypad"},
+					},
+					Exclude: []DeviceRule{
+						{Name: "YubiKey"},
+						{Vendor: deviceID(0x1050), Product: deviceID(0x0407), Bus: "usb"},
+					},
+					CrossDevice: CrossDeviceIsolate,
+				},
+			},
+			wantErr: nil,
+		},
+		{
+			name:           "should return error when concurrency policy is unknown :NEG",
+			configPath:     "./testdata/load_config/invalid_concurrency.yaml",
+			expectedConfig: Config{},
+			wantErr:        ErrInvalidConcurrency,
+		},
+		{
+			name:       "should successfully load concurrency policies :POS",
+			configPath: "./testdata/load_config/valid_concurrency.yaml",
+			expectedConfig: Config{
+				Keybindings: []Keybinding{
+					{
+						Name: "Toggle Recorder",
+						KeyCombination: hotkey.KeyCombo{
+							Modifiers: []uint16{hotkey.KEY_LEFTMETA},
+							Key:       hotkey.KEY_R,
+							Raw:       "super+r",
+						},
+						Run:         Command{Line: "wf-recorder -f ~/rec.mp4"},
+						Concurrency: ConcurrencyToggle,
+					},
+					{
+						Name: "Sync Notes",
+						KeyCombination: hotkey.KeyCombo{
+							Modifiers: []uint16{hotkey.KEY_LEFTMETA},
+							Key:       hotkey.KEY_S,
+							Raw:       "super+s",
+						},
+						Run:         Command{Line: "git -C ~/notes pull"},
+						Concurrency: ConcurrencyQueue,
+					},
+				},
+			},
+			wantErr: nil,
+		},
+		{
+			name:           "should return error when on is unknown :NEG",
+			configPath:     "./testdata/load_config/invalid_on.yaml",
+			expectedConfig: Config{},
+			wantErr:        ErrInvalidOn,
+		},
+		{
+			name:       "should load bindings of the same keys for other events :POS",
+			configPath: "./testdata/load_config/valid_on.yaml",
+			expectedConfig: Config{
+				Keybindings: []Keybinding{
+					{
+						Name: "Push To Talk",
+						KeyCombination: hotkey.KeyCombo{
+							Modifiers: []uint16{hotkey.KEY_LEFTMETA},
+							Key:       hotkey.KEY_T,
+							Raw:       "super+t",
+						},
+						Run: Command{Line: "pactl set-source-mute @DEFAULT_SOURCE@ 0"},
+					},
+					{
+						Name: "Release To Mute",
+						KeyCombination: hotkey.KeyCombo{
+							Modifiers: []uint16{hotkey.KEY_LEFTMETA},
+							Key:       hotkey.KEY_T,
+							Raw:       "super+t",
+						},
+						Run: Command{Line: "pactl set-source-mute @DEFAULT_SOURCE@ 1"},
+						On:  OnRelease,
+					},
+					{
+						Name: "Volume Up",
+						KeyCombination: hotkey.KeyCombo{
+							Modifiers: []uint16{hotkey.KEY_LEFTMETA},
+							Key:       hotkey.KEY_EQUAL,
+							Raw:       "super+equal",
+						},
+						Run: Command{Line: "pactl set-sink-volume @DEFAULT_SINK@ +2%"},
+						On:  OnHold,
+					},
+				},
+			},
+			wantErr: nil,
+		},
+		{
+			name:           "should return error when timeout is negative :NEG",
+			configPath:     "./testdata/load_config/negative_timeout.yaml",
+			expectedConfig: Config{},
+			wantErr:        ErrNegativeTimeout,
+		},
+		{
+			name:       "should fill unset timeouts from defaults :POS",
+			configPath: "./testdata/load_config/valid_timeout.yaml",
+			expectedConfig: Config{
+				Defaults: Defaults{Timeout: time.Minute},
+				Keybindings: []Keybinding{
+					{
+						Name: "Mount Share",
+						KeyCombination: hotkey.KeyCombo{
+							Modifiers: []uint16{hotkey.KEY_LEFTMETA},
+							Key:       hotkey.KEY_M,
+							Raw:       "super+m",
+						},
+						Run:     Command{Line: "mount-share"},
+						Timeout: 30 * time.Second,
+					},
+					{
+						Name: "Backup",
+						KeyCombination: hotkey.KeyCombo{
+							Modifiers: []uint16{hotkey.KEY_LEFTMETA},
+							Key:       hotkey.KEY_B,
+							Raw:       "super+b",
+						},
+						File:    "~/scripts/backup.sh",
+						Timeout: time.Minute,
+					},
+				},
+			},
+			wantErr: nil,
+		},
+		{
+			name:           "should return error when output is unknown :NEG",
+			configPath:     "./testdata/load_config/invalid_output.yaml",
+			expectedConfig: Config{},
+			wantErr:        ErrInvalidOutput,
+		},
+		{
+			name:       "should fill unset output from defaults :POS",
+			configPath: "./testdata/load_config/valid_output.yaml",
+			expectedConfig: Config{
+				Defaults: Defaults{Output: OutputLog},
+				Keybindings: []Keybinding{
+					{
+						Name: "Open Alacritty",
+						KeyCombination: hotkey.KeyCombo{
+							Modifiers: []uint16{hotkey.KEY_LEFTCTRL, hotkey.KEY_LEFTALT},
+							Key:       hotkey.KEY_T,
+							Raw:       "ctrl+alt+t",
+						},
+						Run:    Command{Line: "alacritty"},
+						Output: OutputLog,
+					},
+					{
+						Name: "Backup",
+						KeyCombination: hotkey.KeyCombo{
+							Modifiers: []uint16{hotkey.KEY_LEFTMETA},
+							Key:       hotkey.KEY_B,
+							Raw:       "super+b",
+						},
+						File:   "~/scripts/backup.sh",
+						Output: OutputInherit,
+					},
+				},
+			},
+			wantErr: nil,
+		},
+		{
+			name:           "should return error when env file is malformed :NEG",
+			configPath:     "./testdata/load_config/invalid_env_file.yaml",
+			expectedConfig: Config{},
+			wantErr:        ErrInvalidEnvFile,
+		},
+		{
+			name:       "should merge binding environment over defaults :POS",
+			configPath: "./testdata/load_config/valid_env.yaml",
+			expectedConfig: Config{
+				Defaults: Defaults{
+					Env:     map[string]string{"TERMINAL": "alacritty"},
+					EnvFile: "valid.env",
+					Cwd:     "$HOME",
+					Shell:   "bash",
+				},
+				Keybindings: []Keybinding{
+					{
+						Name: "Edit Notes",
+						KeyCombination: hotkey.KeyCombo{
+							Modifiers: []uint16{hotkey.KEY_LEFTMETA},
+							Key:       hotkey.KEY_N,
+							Raw:       "super+n",
+						},
+						Run: Command{Line: "$TERMINAL -e $EDITOR notes.md"},
+						Env: map[string]string{
+							"BROWSER":  "firefox",
+							"EDITOR":   "nvim",
+							"TERMINAL": "alacritty",
+						},
+						Cwd:   "~/notes",
+						Shell: "bash",
+					},
+					{
+						Name: "Open Browser",
+						KeyCombination: hotkey.KeyCombo{
+							Modifiers: []uint16{hotkey.KEY_LEFTMETA},
+							Key:       hotkey.KEY_W,
+							Raw:       "super+w",
+						},
+						Run: Command{Line: "$BROWSER"},
+						Env: map[string]string{
+							"BROWSER":  "firefox",
+							"EDITOR":   "vim",
+							"TERMINAL": "alacritty",
+						},
+						Cwd:   "$HOME",
+						Shell: "zsh",
+					},
+				},
+			},
+			wantErr: nil,
+		},
+		{
+			name:           "should return error when user does not exist :NEG",
+			configPath:     "./testdata/load_config/unknown_user.yaml",
+			expectedConfig: Config{},
+			wantErr:        ErrUnknownUser,
+		},
+		{
+			name:           "should return error when the import_env user does not exist :NEG",
+			configPath:     "./testdata/load_config/import_env_unknown_user.yaml",
+			expectedConfig: Config{},
+			wantErr:        ErrUnknownUser,
+		},
+		{
+			name:           "should return error when actions are combined with run :NEG",
+			configPath:     "./testdata/load_config/actions_and_run.yaml",
+			expectedConfig: Config{},
+			wantErr:        ErrMultipleActions,
+		},
+		{
+			name:           "should return error when a step has multiple actions :NEG",
+			configPath:     "./testdata/load_config/actions_multi_action_step.yaml",
+			expectedConfig: Config{},
+			wantErr:        ErrMultipleActions,
+		},
+		{
+			name:           "should return error when parallel steps are nested :NEG",
+			configPath:     "./testdata/load_config/actions_nested_parallel.yaml",
+			expectedConfig: Config{},
+			wantErr:        ErrNestedParallel,
+		},
+		{
+			name:       "should successfully load action pipeline :POS",
+			configPath: "./testdata/load_config/valid_actions.yaml",
+			expectedConfig: Config{
+				Keybindings: []Keybinding{
+					{
+						Name: "Screenshot",
+						KeyCombination: hotkey.KeyCombo{
+							Modifiers: []uint16{hotkey.KEY_LEFTMETA},
+							Key:       hotkey.KEY_PRINT,
+							Raw:       "super+print",
+						},
+						Actions: []Step{
+							{Run: Command{Line: "grim /tmp/shot.png"}},
+							{
+								Parallel: []Step{
+									{Run: Command{Line: "wl-copy < /tmp/shot.png"}},
+									{File: "~/scripts/upload.sh"},
+								},
+								ContinueOnError: true,
+							},
+							{Run: Command{Line: `notify-send "Screenshot taken"`}},
+						},
+					},
+				},
+			},
+			wantErr: nil,
+		},
+		{
+			name:           "should return error for unknown built-in action :NEG",
+			configPath:     "./testdata/load_config/builtin_unknown_action.yaml",
+			expectedConfig: Config{},
+			wantErr:        ErrUnknownAction,
+		},
+		{
+			name:           "should return error when built-in action misses its argument :NEG",
+			configPath:     "./testdata/load_config/builtin_missing_argument.yaml",
+			expectedConfig: Config{},
+			wantErr:        ErrActionArgument,
+		},
+		{
+			name:           "should return error when built-in action names unknown binding :NEG",
+			configPath:     "./testdata/load_config/builtin_unknown_binding.yaml",
+			expectedConfig: Config{},
+			wantErr:        ErrUnknownBinding,
+		},
+		{
+			name:           "should return error when built-in action names unknown mode :NEG",
+			configPath:     "./testdata/load_config/builtin_unknown_mode.yaml",
+			expectedConfig: Config{},
+			wantErr:        ErrUnknownMode,
+		},
+		{
+			name:       "should successfully load built-in actions and modes :POS",
+			configPath: "./testdata/load_config/valid_builtin.yaml",
+			expectedConfig: Config{
+				Keybindings: []Keybinding{
+					{
+						Name: "Resize Mode",
+						KeyCombination: hotkey.KeyCombo{
+							Modifiers: []uint16{hotkey.KEY_LEFTMETA},
+							Key:       hotkey.KEY_R,
+							Raw:       "super+r",
+						},
+						Action: "mode resize",
+					},
+					{
+						Name: "Grow",
+						KeyCombination: hotkey.KeyCombo{
+							Modifiers: []uint16{hotkey.KEY_LEFTMETA},
+							Key:       hotkey.KEY_L,
+							Raw:       "super+l",
+						},
+						Mode: "resize",
+						Run:  Command{Line: "swaymsg resize grow width 10px"},
+					},
+					{
+						Name: "Leave Resize",
+						KeyCombination: hotkey.KeyCombo{
+							Modifiers: []uint16{hotkey.KEY_LEFTMETA},
+							Key:       hotkey.KEY_R,
+							Raw:       "super+r",
+						},
+						Mode:   "resize",
+						Action: "mode default",
+					},
+					{
+						Name: "Pause",
+						KeyCombination: hotkey.KeyCombo{
+							Modifiers: []uint16{hotkey.KEY_LEFTMETA},
+							Key:       hotkey.KEY_PAUSE,
+							Raw:       "super+pause",
+						},
+						Action: "toggle-pause",
+					},
+				},
+			},
+			wantErr: nil,
+		},
+		{
+			name:           "should return error for empty condition :NEG",
+			configPath:     "./testdata/load_config/when_empty.yaml",
+			expectedConfig: Config{},
+			wantErr:        ErrEmptyCondition,
+		},
+		{
+			name:           "should return error for invalid time window :NEG",
+			configPath:     "./testdata/load_config/when_invalid_time.yaml",
+			expectedConfig: Config{},
+			wantErr:        ErrInvalidTimeWindow,
+		},
+		{
+			name:           "should return error when mode and condition mode differ :NEG",
+			configPath:     "./testdata/load_config/when_mode_conflict.yaml",
+			expectedConfig: Config{},
+			wantErr:        ErrModeConflict,
+		},
+		{
+			name:           "should return error for two unguarded bindings with the same keys :NEG",
+			configPath:     "./testdata/load_config/when_duplicate_fallback.yaml",
+			expectedConfig: Config{},
+			wantErr:        ErrDuplicateKeybinding,
+		},
+		{
+			name:       "should successfully load guarded bindings :POS",
+			configPath: "./testdata/load_config/valid_when.yaml",
+			expectedConfig: Config{
+				Keybindings: []Keybinding{
+					{
+						Name: "Steam Overlay",
+						KeyCombination: hotkey.KeyCombo{
+							Modifiers: []uint16{hotkey.KEY_LEFTMETA},
+							Key:       hotkey.KEY_G,
+							Raw:       "super+g",
+						},
+						When:     &When{Command: "pgrep -x steam"},
+						Priority: 10,
+						Run:      Command{Line: "steam steam://open/overlay"},
+					},
+					{
+						Name: "Work Browser",
+						KeyCombination: hotkey.KeyCombo{
+							Modifiers: []uint16{hotkey.KEY_LEFTMETA},
+							Key:       hotkey.KEY_G,
+							Raw:       "super+g",
+						},
+						When: &When{Env: "XDG_SESSION_TYPE=wayland", Time: "09:00-17:00"},
+						Run:  Command{Line: "firefox -P work"},
+					},
+					{
+						Name: "Browser",
+						KeyCombination: hotkey.KeyCombo{
+							Modifiers: []uint16{hotkey.KEY_LEFTMETA},
+							Key:       hotkey.KEY_G,
+							Raw:       "super+g",
+						},
+						Run: Command{Line: "firefox"},
+					},
+				},
+			},
+			wantErr: nil,
+		},
+		{
+			name:           "should return error for negative cooldown :NEG",
+			configPath:     "./testdata/load_config/negative_cooldown.yaml",
+			expectedConfig: Config{},
+			wantErr:        ErrNegativeCooldown,
+		},
+		{
+			name:           "should return error for negative debounce :NEG",
+			configPath:     "./testdata/load_config/negative_debounce.yaml",
+			expectedConfig: Config{},
+			wantErr:        ErrNegativeDebounce,
+		},
+		{
+			name:           "should return error for negative spawn limit :NEG",
+			configPath:     "./testdata/load_config/negative_spawn_limit.yaml",
+			expectedConfig: Config{},
+			wantErr:        ErrInvalidSpawnLimit,
+		},
+		{
+			name:       "should successfully load cooldown, debounce and limits :POS",
+			configPath: "./testdata/load_config/valid_limits.yaml",
+			expectedConfig: Config{
+				Limits: Limits{MaxSpawnsPerSecond: 5},
+				Keybindings: []Keybinding{
+					{
+						Name: "Browser",
+						KeyCombination: hotkey.KeyCombo{
+							Modifiers: []uint16{hotkey.KEY_LEFTMETA},
+							Key:       hotkey.KEY_G,
+							Raw:       "super+g",
+						},
+						Run:      Command{Line: "firefox"},
+						Cooldown: 2 * time.Second,
+						Debounce: 300 * time.Millisecond,
+					},
+				},
+			},
+			wantErr: nil,
+		},
+		{
+			name:           "should return error for args without file :NEG",
+			configPath:     "./testdata/load_config/args_without_file.yaml",
+			expectedConfig: Config{},
+			wantErr:        ErrArgsNeedFile,
+		},
+		{
+			name:           "should return error for run list without program :NEG",
+			configPath:     "./testdata/load_config/run_empty_program.yaml",
+			expectedConfig: Config{},
+			wantErr:        ErrEmptyArgument,
+		},
+		{
+			name:       "should successfully load argument lists :POS",
+			configPath: "./testdata/load_config/valid_argv.yaml",
+			expectedConfig: Config{
+				Keybindings: []Keybinding{
+					{
+						Name: "Terminal",
+						KeyCombination: hotkey.KeyCombo{
+							Modifiers: []uint16{hotkey.KEY_LEFTCTRL, hotkey.KEY_LEFTALT},
+							Key:       hotkey.KEY_T,
+							Raw:       "ctrl+alt+t",
+						},
+						Run: Command{Argv: []string{"alacritty", "-e", "htop"}},
+					},
+					{
+						Name: "Backup",
+						KeyCombination: hotkey.KeyCombo{
+							Modifiers: []uint16{hotkey.KEY_LEFTMETA},
+							Key:       hotkey.KEY_B,
+							Raw:       "super+b",
+						},
+						File: "~/scripts/backup.sh",
+						Args: []string{"--full", "/srv/docs"},
+					},
+					{
+						Name: "Sync",
+						KeyCombination: hotkey.KeyCombo{
+							Modifiers: []uint16{hotkey.KEY_LEFTMETA},
+							Key:       hotkey.KEY_S,
+							Raw:       "super+s",
+						},
+						Actions: []Step{
+							{Run: Command{Argv: []string{"notify-send", "Sync started"}}},
+							{File: "~/scripts/sync.sh", Args: []string{"--quiet"}},
+						},
+					},
+				},
+			},
+			wantErr: nil,
+		},
+		{
+			name:           "should return error for hook list without program :NEG",
+			configPath:     "./testdata/load_config/hook_empty_program.yaml",
+			expectedConfig: Config{},
+			wantErr:        ErrEmptyArgument,
+		},
+		{
+			name:       "should successfully load hooks :POS",
+			configPath: "./testdata/load_config/valid_hooks.yaml",
+			expectedConfig: Config{
+				OnError: Command{Line: `notify-send "ghkd" "$GHKD_BINDING failed"`},
+				Keybindings: []Keybinding{
+					{
+						Name: "Backup",
+						KeyCombination: hotkey.KeyCombo{
+							Modifiers: []uint16{hotkey.KEY_LEFTMETA},
+							Key:       hotkey.KEY_B,
+							Raw:       "super+b",
+						},
+						File:      "~/scripts/backup.sh",
+						OnSuccess: Command{Argv: []string{"notify-send", "Backup done"}},
+						OnFailure: Command{Line: `notify-send "Backup failed"`},
+					},
+				},
+			},
+			wantErr: nil,
+		},
 		{
 			name:       "should successfully load valid configuration :POS",
 			configPath: "./testdata/load_config/valid_config.yaml",
@@ -211,7 +912,7 @@ func TestConfig_LoadConfig(t *testing.T) {
 							Key:       hotkey.KEY_T,
 							Raw:       "ctrl+alt+t",
 						},
-						Run: "alacritty",
+						Run: Command{Line: "alacritty"},
 					},
 					{
 						Name: "System Info",
@@ -0,0 +1,93 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ExpandPath expands $VARS and a leading ~/ in a path. Variables, HOME
+// included, are looked up in env first, then in the daemon's environment.
+func ExpandPath(path string, env map[string]string) string {
+	path = os.Expand(path, func(name string) string {
+		if value, ok := env[name]; ok {
+			return value
+		}
+		return os.Getenv(name)
+	})
+
+	if strings.HasPrefix(path, "~/") {
+		home, ok := env["HOME"]
+		if !ok {
+			var err error
+			if home, err = os.UserHomeDir(); err != nil {
+				return path // Return original if home dir fails
+			}
+		}
+		return filepath.Join(home, path[2:])
+	}
+	return path
+}
+
+// mergeEnv returns the variables of an env file overridden by env. A
+// relative env file is read from baseDir.
+func mergeEnv(envFile string, env map[string]string, baseDir string) (map[string]string, error) {
+	merged := map[string]string{}
+
+	if envFile != "" {
+		path := ExpandPath(envFile, nil)
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(baseDir, path)
+		}
+
+		fileEnv, err := ReadEnvFile(path)
+		if err != nil {
+			return nil, err
+		}
+		maps.Copy(merged, fileEnv)
+	}
+
+	maps.Copy(merged, env)
+
+	if len(merged) == 0 {
+		return nil, nil
+	}
+	return merged, nil
+}
+
+// ReadEnvFile reads KEY=VALUE lines from a dotenv file. Blank lines, #
+// comments, an "export " prefix and quotes around values are allowed.
+func ReadEnvFile(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	env := map[string]string{}
+	scanner := bufio.NewScanner(file)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		key, value, found := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !found || key == "" || strings.ContainsAny(key, " \t") {
+			return nil, fmt.Errorf("%s:%d: %w", path, lineNum, ErrInvalidEnvFile)
+		}
+
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		env[key] = value
+	}
+
+	return env, scanner.Err()
+}
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// When is the condition a binding needs to fire. Every field that is set
+// must hold. Written as a plain string it is a shell command.
+type When struct {
+	Command string `yaml:"command,omitempty"` // Shell command that must exit 0: "pgrep -x steam"
+	File    string `yaml:"file,omitempty"`    // Path that must exist: "~/.presenting"
+	Env     string `yaml:"env,omitempty"`     // Variable that must be set: "SSH_TTY", or have a value: "XDG_SESSION_TYPE=wayland"
+	Mode    string `yaml:"mode,omitempty"`    // Active ghkd mode, same as the binding's 'mode'
+	Time    string `yaml:"time,omitempty"`    // Local time window: "09:00-17:00", "22:00-06:00"
+}
+
+// UnmarshalYAML implements custom YAML unmarshaling
+func (w *When) UnmarshalYAML(unmarshal func(any) error) error {
+	var command string
+	if err := unmarshal(&command); err == nil {
+		*w = When{Command: command}
+		return nil
+	}
+
+	// Without the methods, so unmarshaling doesn't recurse
+	type plain When
+	return unmarshal((*plain)(w))
+}
+
+// Guarded reports whether the condition checks more than the mode
+func (w *When) Guarded() bool {
+	return w != nil && (w.Command != "" || w.File != "" || w.Env != "" || w.Time != "")
+}
+
+// ParseTimeWindow parses a "15:04-15:04" window into offsets from
+// midnight. A window whose end is before its start wraps past midnight.
+func ParseTimeWindow(window string) (start, end time.Duration, err error) {
+	from, to, found := strings.Cut(window, "-")
+	if !found {
+		return 0, 0, fmt.Errorf("%s: %w", window, ErrInvalidTimeWindow)
+	}
+
+	start, err = parseClock(from)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%s: %w", window, ErrInvalidTimeWindow)
+	}
+	end, err = parseClock(to)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%s: %w", window, ErrInvalidTimeWindow)
+	}
+	return start, end, nil
+}
+
+// parseClock parses "15:04" into an offset from midnight
+func parseClock(clock string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(clock))
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// validateWhen checks a binding's condition
+func validateWhen(kb Keybinding) error {
+	w := kb.When
+	if w == nil {
+		return nil
+	}
+
+	if *w == (When{}) {
+		return ErrEmptyCondition
+	}
+
+	if w.Mode != "" && kb.Mode != "" && w.Mode != kb.Mode {
+		return ErrModeConflict
+	}
+
+	if w.Time != "" {
+		if _, _, err := ParseTimeWindow(w.Time); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package executor
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/glowfi/ghkd/internal/config"
+)
+
+// sessionPath is the PATH of actions running as another user, who don't
+// inherit the daemon's
+const sessionPath = "/usr/local/bin:/usr/bin:/bin"
+
+// x11SocketDir holds the sockets of the running X servers
+const x11SocketDir = "/tmp/.X11-unix"
+
+// account is a user an action runs as instead of the daemon's user
+type account struct {
+	name   string
+	uid    uint32
+	gid    uint32
+	groups []uint32
+	home   string
+}
+
+// lookupAccount resolves the user of a binding. It returns nil when the
+// action runs as the daemon's own user.
+func lookupAccount(name string) (*account, error) {
+	if name == "" {
+		return nil, nil
+	}
+
+	u, err := config.LookupUser(name)
+	if err != nil {
+		return nil, err
+	}
+
+	uid, err := strconv.ParseUint(u.Uid, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("user %s: uid %s: %w", name, u.Uid, err)
+	}
+	if int(uid) == os.Geteuid() {
+		return nil, nil
+	}
+	if os.Geteuid() != 0 {
+		return nil, fmt.Errorf("run as %s: the daemon is not running as root", name)
+	}
+
+	gid, err := strconv.ParseUint(u.Gid, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("user %s: gid %s: %w", name, u.Gid, err)
+	}
+
+	groupIDs, err := u.GroupIds()
+	if err != nil {
+		return nil, fmt.Errorf("user %s: groups: %w", name, err)
+	}
+	groups := make([]uint32, 0, len(groupIDs))
+	for _, id := range groupIDs {
+		if g, err := strconv.ParseUint(id, 10, 32); err == nil {
+			groups = append(groups, uint32(g))
+		}
+	}
+
+	return &account{
+		name:   u.Username,
+		uid:    uint32(uid),
+		gid:    uint32(gid),
+		groups: groups,
+		home:   u.HomeDir,
+	}, nil
+}
+
+// id returns the uid actions of the account run as, the daemon's own for
+// a nil account
+func (a *account) id() uint32 {
+	if a == nil {
+		return uint32(os.Geteuid())
+	}
+	return a.uid
+}
+
+// credential drops the action's privileges to the account
+func (a *account) credential() *syscall.Credential {
+	return &syscall.Credential{Uid: a.uid, Gid: a.gid, Groups: a.groups}
+}
+
+// apply returns a copy of the keybinding with the account's session
+// environment under the binding's own variables. Without a cwd the action
+// starts in the account's home, if it has one.
+func (a *account) apply(kb *config.Keybinding) *config.Keybinding {
+	resolved := *kb
+
+	env := a.sessionEnv()
+	maps.Copy(env, kb.Env)
+	resolved.Env = env
+
+	if info, err := os.Stat(a.home); resolved.Cwd == "" && err == nil && info.IsDir() {
+		resolved.Cwd = a.home
+	}
+	return &resolved
+}
+
+// sessionEnv returns the variables that identify the account and connect
+// an action to its graphical and D-Bus session. Sockets are found in the
+// runtime directory, /run/user/<uid>, and X servers by their owner.
+func (a *account) sessionEnv() map[string]string {
+	env := map[string]string{
+		"HOME":    a.home,
+		"USER":    a.name,
+		"LOGNAME": a.name,
+		"PATH":    sessionPath,
+	}
+	if display := x11Display(x11SocketDir, a.uid); display != "" {
+		env["DISPLAY"] = display
+	}
+
+	runtimeDir := fmt.Sprintf("/run/user/%d", a.uid)
+	if _, err := os.Stat(runtimeDir); err != nil {
+		return env
+	}
+	env["XDG_RUNTIME_DIR"] = runtimeDir
+
+	if display := waylandDisplay(runtimeDir); display != "" {
+		env["WAYLAND_DISPLAY"] = display
+	}
+	if bus := filepath.Join(runtimeDir, "bus"); isSocket(bus) {
+		env["DBUS_SESSION_BUS_ADDRESS"] = "unix:path=" + bus
+	}
+	return env
+}
+
+// waylandDisplay returns the first compositor socket in a runtime directory
+func waylandDisplay(runtimeDir string) string {
+	matches, _ := filepath.Glob(filepath.Join(runtimeDir, "wayland-*"))
+	slices.Sort(matches)
+	for _, match := range matches {
+		if !strings.HasSuffix(match, ".lock") && isSocket(match) {
+			return filepath.Base(match)
+		}
+	}
+	return ""
+}
+
+// x11Display returns the first display in socketDir whose socket uid
+// owns. Servers of other users are never picked, the user couldn't
+// connect or would end up on someone else's screen.
+func x11Display(socketDir string, uid uint32) string {
+	matches, _ := filepath.Glob(filepath.Join(socketDir, "X*"))
+	slices.Sort(matches)
+	for _, match := range matches {
+		info, err := os.Lstat(match)
+		if err != nil || info.Mode()&os.ModeSocket == 0 {
+			continue
+		}
+		if stat, ok := info.Sys().(*syscall.Stat_t); ok && stat.Uid == uid {
+			return ":" + strings.TrimPrefix(filepath.Base(match), "X")
+		}
+	}
+	return ""
+}
+
+func isSocket(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode()&os.ModeSocket != 0
+}
@@ -0,0 +1,55 @@
+package executor
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccount_X11Display(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("needs root to give sockets to another user")
+	}
+
+	tests := []struct {
+		name    string
+		sockets map[string]uint32 // socket name to owner
+		uid     uint32
+		want    string
+	}{
+		{
+			name:    "should use the display of the user :POS",
+			sockets: map[string]uint32{"X0": 0, "X1": 65534},
+			uid:     65534,
+			want:    ":1",
+		},
+		{
+			name:    "should not use another user's display :NEG",
+			sockets: map[string]uint32{"X0": 0},
+			uid:     65534,
+			want:    "",
+		},
+		{
+			name: "should leave the display unset without servers :NEG",
+			uid:  65534,
+			want: "",
+		},
+	}
+
+	for _, tt := range tests {
+		dir := t.TempDir()
+		for name, owner := range tt.sockets {
+			path := filepath.Join(dir, name)
+			listener, err := net.Listen("unix", path)
+			require.NoError(t, err, tt.name)
+			defer listener.Close()
+			require.NoError(t, os.Lchown(path, int(owner), int(owner)), tt.name)
+		}
+
+		assert.Equal(t, tt.want, x11Display(dir, tt.uid), tt.name)
+	}
+}
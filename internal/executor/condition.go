@@ -0,0 +1,36 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/glowfi/ghkd/internal/config"
+)
+
+// conditionTimeout bounds a 'when' command. Commands run on every press of
+// their keys, so they must be quick.
+const conditionTimeout = time.Second
+
+// Succeeds runs a 'when' command of a binding and reports whether it
+// exited 0. It runs like the binding's action would: with its shell, as
+// its user, with the imported session variables and its env. Its output
+// is discarded.
+func (e *Executor) Succeeds(ctx context.Context, kb *config.Keybinding, command string) bool {
+	ctx, cancel := context.WithTimeout(ctx, conditionTimeout)
+	defer cancel()
+
+	check := stepBinding(kb, config.Step{Run: config.Command{Line: command}})
+	check.Output = config.OutputDiscard
+
+	status := e.runProcess(ctx, newRun(kb.Name+" when"), check)
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		log.Printf("Warning: %s: condition '%s' took longer than %s", kb.Name, command, conditionTimeout)
+		return false
+	}
+	if status.Err != nil {
+		log.Printf("Warning: %s: condition '%s': %v", kb.Name, command, status.Err)
+	}
+	return !status.Failed()
+}
@@ -0,0 +1,48 @@
+package executor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/glowfi/ghkd/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecutor_Succeeds(t *testing.T) {
+	tests := []struct {
+		name    string
+		kb      config.Keybinding
+		command string
+		want    bool
+	}{
+		{
+			name:    "should see the binding's env :POS",
+			kb:      config.Keybinding{Name: "Test", Env: map[string]string{"GHKD_TEST": "yes"}},
+			command: `test "$GHKD_TEST" = yes`,
+			want:    true,
+		},
+		{
+			name:    "should run with the binding's shell :POS",
+			kb:      config.Keybinding{Name: "Test", Shell: "bash"},
+			command: `[[ -n "$BASH_VERSION" ]]`,
+			want:    true,
+		},
+		{
+			name:    "should fail when the command fails :NEG",
+			kb:      config.Keybinding{Name: "Test"},
+			command: "exit 1",
+			want:    false,
+		},
+		{
+			name:    "should fail when the command can't start :NEG",
+			kb:      config.Keybinding{Name: "Test", Cwd: "/nonexistent/ghkd"},
+			command: "true",
+			want:    false,
+		},
+	}
+
+	for _, tt := range tests {
+		e := New()
+		assert.Equal(t, tt.want, e.Succeeds(context.Background(), &tt.kb, tt.command), tt.name)
+	}
+}
@@ -0,0 +1,40 @@
+package executor
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"slices"
+
+	"github.com/glowfi/ghkd/internal/config"
+)
+
+// setEnvironment sets the working directory and the variables of an
+// action. An action of the daemon's own user gets them on top of the
+// daemon's environment; one running as acct gets only its own, so nothing
+// of the daemon's environment reaches another user.
+func setEnvironment(cmd *exec.Cmd, kb *config.Keybinding, acct *account) error {
+	if kb.Cwd != "" {
+		dir := config.ExpandPath(kb.Cwd, kb.Env)
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			return fmt.Errorf("cwd %s is not a directory", dir)
+		}
+		cmd.Dir = dir
+	}
+
+	env := []string{}
+	if acct == nil {
+		if len(kb.Env) == 0 {
+			return nil
+		}
+		env = os.Environ()
+	}
+
+	// Later entries win, so the binding's variables override the daemon's
+	for _, key := range slices.Sorted(maps.Keys(kb.Env)) {
+		env = append(env, key+"="+kb.Env[key])
+	}
+	cmd.Env = env
+	return nil
+}
@@ -0,0 +1,150 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/glowfi/ghkd/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnvironment_SetEnvironment(t *testing.T) {
+	t.Setenv("GHKD_TEST_DAEMON", "secret")
+
+	tests := []struct {
+		name        string
+		env         map[string]string
+		acct        *account
+		wantInherit bool     // the daemon's environment is passed on
+		want        []string // variables set on top
+	}{
+		{
+			name:        "should inherit the daemon's environment without variables :POS",
+			wantInherit: true,
+		},
+		{
+			name:        "should add the binding's variables to the daemon's :POS",
+			env:         map[string]string{"A": "1"},
+			wantInherit: true,
+			want:        []string{"A=1"},
+		},
+		{
+			name: "should pass only the account's own variables :NEG",
+			env:  map[string]string{"HOME": "/home/jane", "A": "1"},
+			acct: &account{name: "jane", uid: 1000, gid: 1000},
+			want: []string{"A=1", "HOME=/home/jane"},
+		},
+		{
+			name: "should pass an empty environment to an account without variables :NEG",
+			acct: &account{name: "jane", uid: 1000, gid: 1000},
+			want: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		cmd := exec.Command("true")
+		err := setEnvironment(cmd, &config.Keybinding{Env: tt.env}, tt.acct)
+		require.NoError(t, err, tt.name)
+
+		if tt.wantInherit {
+			if tt.want == nil {
+				assert.Nil(t, cmd.Env, tt.name)
+				continue
+			}
+			assert.Contains(t, cmd.Env, "GHKD_TEST_DAEMON=secret", tt.name)
+			assert.Subset(t, cmd.Env, tt.want, tt.name)
+			continue
+		}
+		assert.Equal(t, tt.want, cmd.Env, tt.name)
+	}
+}
+
+func TestEnvironment_Execute(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "sub"), 0o755))
+	t.Setenv("GHKD_TEST_DIR", dir)
+	t.Setenv("GHKD_TEST_DAEMON", "daemon")
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+	cfg, err := config.LoadConfig("./testdata/environment.yaml")
+	require.NoError(t, err, "expect config to load")
+
+	tests := []struct {
+		name       string
+		binding    string
+		want       string // output of the action
+		wantStatus string
+	}{
+		{
+			name:       "should start in the default cwd :POS",
+			binding:    "Default Cwd",
+			want:       dir + "\n",
+			wantStatus: "exit 0",
+		},
+		{
+			name:       "should start in the binding's own cwd :POS",
+			binding:    "Own Cwd",
+			want:       filepath.Join(dir, "sub") + "\n",
+			wantStatus: "exit 0",
+		},
+		{
+			name:       "should refuse a cwd that doesn't exist :NEG",
+			binding:    "Missing Cwd",
+			wantStatus: fmt.Sprintf("error: cwd %s is not a directory", filepath.Join(dir, "missing")),
+		},
+		{
+			name:       "should merge the defaults over the env file and the daemon :POS",
+			binding:    "Default Env",
+			want:       "file defaults daemon\n",
+			wantStatus: "exit 0",
+		},
+		{
+			name:       "should let the binding's env win over the defaults :POS",
+			binding:    "Own Env",
+			want:       "binding binding\n",
+			wantStatus: "exit 0",
+		},
+		{
+			name:       "should let trigger variables win over the binding's env :NEG",
+			binding:    "Trigger Env",
+			want:       "Trigger Env\n",
+			wantStatus: "exit 0",
+		},
+		{
+			name:       "should run commands with the binding's shell :POS",
+			binding:    "Shell",
+			want:       "bash\n",
+			wantStatus: "exit 0",
+		},
+	}
+
+	for _, tt := range tests {
+		idx := slices.IndexFunc(cfg.Keybindings, func(kb config.Keybinding) bool { return kb.Name == tt.binding })
+		require.GreaterOrEqual(t, idx, 0, tt.name)
+
+		e := New()
+		require.NoError(t, e.Execute(context.Background(), &cfg.Keybindings[idx], Trigger{}), tt.name)
+		waitIdle(t, e)
+
+		entries := e.History(tt.binding)
+		require.Len(t, entries, 1, tt.name)
+		assert.Equal(t, tt.wantStatus, entries[0].Status, tt.name)
+
+		if tt.want != "" {
+			path, err := LogPath(tt.binding)
+			require.NoError(t, err, tt.name)
+			data, err := os.ReadFile(path)
+			require.NoError(t, err, tt.name)
+			_, output, _ := strings.Cut(string(data), "\n") // after the run header
+			assert.Equal(t, tt.want, output, tt.name)
+		}
+		assert.NoError(t, e.Shutdown(), tt.name)
+	}
+}
@@ -4,202 +4,390 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log"
 	"os"
 	"os/exec"
-	"path/filepath"
-	"strings"
+	"slices"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/glowfi/ghkd/internal/config"
 )
 
+const (
+	// killGrace is how long a stopped action may take to exit before it is killed
+	killGrace = 2 * time.Second
+
+	// shutdownTimeout is how long Shutdown waits for actions to exit before
+	// killing them
+	shutdownTimeout = 5 * time.Second
+
+	// maxQueued is how many triggers of a queue binding may wait for its
+	// running action, further ones are dropped
+	maxQueued = 10
+)
+
 // Executor runs commands and scripts
 type Executor struct {
 	mu      sync.Mutex
-	running map[string]*exec.Cmd
+	launch  sync.Mutex // serializes concurrency decisions and starts
+	running map[string][]*run
+	queued  map[string][]queuedRun
+	imports envImport
+	limits  limiter
+	scripts scriptStore
+	onError config.Command // global hook, guarded by mu
+	history history
+	closed  bool // set by Shutdown, guarded by launch
+
+	// active counts started runs, pending restarts and toggle stops, and
+	// hooks, so Idle can tell when the executor has nothing left to do
+	active sync.WaitGroup
+}
+
+// queuedRun is a trigger waiting for the running action of its binding
+type queuedRun struct {
+	ctx  context.Context
+	kb   *config.Keybinding
+	trig Trigger
 }
 
 // New creates a new executor
 func New() *Executor {
 	return &Executor{
-		running: make(map[string]*exec.Cmd),
+		running: make(map[string][]*run),
+		queued:  make(map[string][]queuedRun),
+		imports: envImport{sent: make(map[uint32]map[string]string)},
+		limits:  newLimiter(),
+		scripts: scriptStore{dir: ScriptDir()},
 	}
 }
 
-// Execute runs the action for a keybinding
-func (e *Executor) Execute(ctx context.Context, kb *config.Keybinding) error {
-	switch {
-	case kb.Run != "":
-		return e.executeCommand(ctx, kb)
-	case kb.Script != "" && kb.Interpreter != "":
-		return e.executeScript(ctx, kb)
-	case kb.File != "":
-		return e.executeFile(ctx, kb)
-	default:
-		return fmt.Errorf("no action defined for keybinding: %s", kb.Name)
+// Execute runs the action for a keybinding, following its concurrency
+// policy when the action is still running. trig is passed to the action in
+// GHKD_* variables.
+func (e *Executor) Execute(ctx context.Context, kb *config.Keybinding, trig Trigger) error {
+	if e.limits.bouncing(kb, time.Now()) {
+		fmt.Printf("Suppressed %s: debounced\n", kb.Name)
+		e.history.suppress(kb.Name, trig, SuppressedDebounce)
+		return nil
 	}
+	return e.execute(ctx, kb, trig)
 }
 
-// executeCommand runs a simple command
-func (e *Executor) executeCommand(ctx context.Context, kb *config.Keybinding) error {
-	cmd := exec.CommandContext(ctx, "sh", "-c", kb.Run)
+// execute applies the cooldown and the concurrency policy to a trigger
+func (e *Executor) execute(ctx context.Context, kb *config.Keybinding, trig Trigger) error {
+	e.launch.Lock()
+	defer e.launch.Unlock()
 
-	// Don't wait for command to finish (async)
-	if err := cmd.Start(); err != nil {
-		return fmt.Errorf("start command: %w", err)
+	if e.closed {
+		return fmt.Errorf("%s: executor is shut down", kb.Name)
 	}
 
-	// Track running command
-	e.trackCommand(kb.Name, cmd)
+	running := e.runs(kb.Name)
 
-	// Wait in background and clean up
-	go func() {
-		if err := cmd.Wait(); err != nil {
-			return
+	// Stopping a toggled action is not a run, the cooldown doesn't apply
+	if len(running) == 0 || kb.Concurrency != config.ConcurrencyToggle {
+		if left := e.limits.cooldownLeft(kb, time.Now()); left > 0 {
+			fmt.Printf("Suppressed %s: cooldown, %s left\n", kb.Name, left.Round(time.Millisecond))
+			e.history.suppress(kb.Name, trig, SuppressedCooldown)
+			return nil
 		}
-		e.untrackCommand(kb.Name)
-	}()
+	}
 
+	if len(running) > 0 {
+		switch kb.Concurrency {
+		case config.ConcurrencySingle:
+			fmt.Printf("Skipped %s: already running\n", kb.Name)
+			e.history.suppress(kb.Name, trig, SuppressedSingle)
+			return nil
+
+		case config.ConcurrencyToggle:
+			fmt.Printf("Stopping %s\n", kb.Name)
+			e.active.Go(func() { e.stop(running) })
+			return nil
+
+		case config.ConcurrencyRestart:
+			fmt.Printf("Restarting %s\n", kb.Name)
+			e.active.Go(func() { e.restart(ctx, kb, trig, running) })
+			return nil
+
+		case config.ConcurrencyQueue:
+			e.mu.Lock()
+			defer e.mu.Unlock()
+			if len(e.queued[kb.Name]) >= maxQueued {
+				fmt.Printf("Suppressed %s: %d triggers already queued\n", kb.Name, maxQueued)
+				e.history.suppress(kb.Name, trig, SuppressedQueue)
+				return nil
+			}
+			e.queued[kb.Name] = append(e.queued[kb.Name], queuedRun{ctx: ctx, kb: kb, trig: trig})
+			return nil
+		}
+	}
+
+	e.start(ctx, kb, trig)
 	return nil
 }
 
-// executeScript runs an inline script with interpreter
-func (e *Executor) executeScript(ctx context.Context, kb *config.Keybinding) error {
-	// Create temp file
-	tmpFile, err := os.CreateTemp("/tmp", fmt.Sprintf("hotkeysd-*%s", kb.Name))
-	if err != nil {
-		return fmt.Errorf("create temp file: %w", err)
+// start runs the action in the background and reports whether the spawn
+// limit let it start. Must be called with e.launch held.
+func (e *Executor) start(ctx context.Context, kb *config.Keybinding, trig Trigger) bool {
+	if !e.limits.allowStart(kb.Name, time.Now()) {
+		fmt.Printf("Suppressed %s: more than %d actions started in the last second\n", kb.Name, e.limits.perSecond)
+		e.history.suppress(kb.Name, trig, SuppressedSpawns)
+		return false
 	}
 
-	tmpPath := tmpFile.Name()
+	e.active.Add(1)
+	kb = withTrigger(kb, trig)
+	r := newRun(kb.Name)
+	r.entry = e.history.begin(kb.Name, trig, r.started)
+	e.trackRun(kb.Name, r)
+
+	var timer *time.Timer
+	if kb.Timeout > 0 {
+		timer = time.AfterFunc(kb.Timeout, func() {
+			log.Printf("Timeout: %s ran longer than %s, terminating", kb.Name, kb.Timeout)
+			r.mu.Lock()
+			r.timedOut = true
+			r.mu.Unlock()
+			if r.terminate() {
+				log.Printf("Timeout: %s killed after %s grace period", kb.Name, killGrace)
+			} else {
+				log.Printf("Timeout: %s exited after SIGTERM", kb.Name)
+			}
+		})
+	}
 
-	// Write script content
-	content := kb.Script
-	if _, err := tmpFile.WriteString(content); err != nil {
-		if err := tmpFile.Close(); err != nil {
-			return err
-		}
-		if err := os.Remove(tmpPath); err != nil {
-			return err
+	go func() {
+		status := e.runSteps(ctx, r, kb)
+		if timer != nil {
+			timer.Stop()
 		}
-		return fmt.Errorf("write script: %w", err)
-	}
-	tmpFile.Close()
+		e.untrackRun(kb.Name, r)
+		close(r.done)
+
+		e.history.finish(r.entry, status)
+		log.Printf("Finished %s: %s in %s", kb.Name, status, status.Duration.Round(time.Millisecond))
+		e.active.Go(func() { e.runHooks(ctx, r, kb, status) })
+		e.startQueued(kb.Name)
+		e.active.Done()
+	}()
+	return true
+}
 
-	// Make executable
-	os.Chmod(tmpPath, 0o700)
+// restart starts a binding again once its stopped runs have exited. The
+// runs are waited for without launch held, so other triggers aren't held
+// up for the kill grace period. If another restart already started the
+// binding meanwhile, that run is kept.
+func (e *Executor) restart(ctx context.Context, kb *config.Keybinding, trig Trigger, running []*run) {
+	e.stop(running)
 
-	// Execute
-	cmd := exec.CommandContext(ctx, kb.Interpreter, tmpPath)
+	e.launch.Lock()
+	defer e.launch.Unlock()
 
-	if err := cmd.Start(); err != nil {
-		if err := os.Remove(tmpPath); err != nil {
-			return err
-		}
-		return fmt.Errorf("start script: %w", err)
+	if e.closed || ctx.Err() != nil || len(e.runs(kb.Name)) > 0 {
+		return
 	}
+	e.start(ctx, kb, trig)
+}
 
-	e.trackCommand(kb.Name, cmd)
-
-	// Wait and clean up
-	go func() {
-		if err := cmd.Wait(); err != nil {
+// startQueued starts the next queued trigger of a binding. Triggers that
+// were cancelled or hit the spawn limit are dropped.
+func (e *Executor) startQueued(name string) {
+	e.launch.Lock()
+	defer e.launch.Unlock()
+
+	for !e.closed {
+		e.mu.Lock()
+		queue := e.queued[name]
+		if len(queue) == 0 || len(e.running[name]) > 0 {
+			e.mu.Unlock()
 			return
 		}
-		if err := os.Remove(tmpPath); err != nil {
+		next := queue[0]
+		if len(queue) == 1 {
+			delete(e.queued, name)
+		} else {
+			e.queued[name] = queue[1:]
+		}
+		e.mu.Unlock()
+
+		if next.ctx.Err() == nil && e.start(next.ctx, next.kb, next.trig) {
 			return
 		}
-		e.untrackCommand(kb.Name)
-	}()
+	}
+}
 
-	return nil
+// stop terminates runs and waits for them to finish
+func (e *Executor) stop(runs []*run) {
+	var wg sync.WaitGroup
+	for _, r := range runs {
+		wg.Go(func() { r.terminate() })
+	}
+	wg.Wait()
 }
 
-func expandPath(path string) string {
-	if strings.HasPrefix(path, "~/") {
-		home, err := os.UserHomeDir()
-		if err != nil {
-			return path // Return original if home dir fails
-		}
-		return filepath.Join(home, path[2:])
+// command builds the process for a keybinding's action. cleanup releases
+// anything the command needed once it has exited. Files the command reads
+// are owned by acct when it is set.
+func (e *Executor) command(ctx context.Context, kb *config.Keybinding, acct *account) (cmd *exec.Cmd, cleanup func(), err error) {
+	switch {
+	case !kb.Run.IsZero():
+		return e.commandRun(ctx, kb)
+	case kb.Script != "" && kb.Interpreter != "":
+		return e.commandScript(ctx, kb, acct)
+	case kb.File != "":
+		return e.commandFile(ctx, kb)
+	default:
+		return nil, nil, fmt.Errorf("no action defined for keybinding: %s", kb.Name)
+	}
+}
+
+// commandRun runs a simple command. A command line goes through the
+// shell, an argument list is executed directly.
+func (e *Executor) commandRun(ctx context.Context, kb *config.Keybinding) (*exec.Cmd, func(), error) {
+	if argv := kb.Run.Argv; len(argv) > 0 {
+		program := config.ExpandPath(argv[0], kb.Env)
+		return exec.CommandContext(ctx, program, argv[1:]...), func() {}, nil
 	}
-	return path
+
+	shell := kb.Shell
+	if shell == "" {
+		shell = "sh"
+	}
+	return exec.CommandContext(ctx, shell, "-c", kb.Run.Line), func() {}, nil
 }
 
-// executeFile runs an external script file
-func (e *Executor) executeFile(ctx context.Context, kb *config.Keybinding) error {
-	path := expandPath(kb.File)
+// commandScript runs an inline script with interpreter. The script is
+// written once and shared by every run.
+func (e *Executor) commandScript(ctx context.Context, kb *config.Keybinding, acct *account) (*exec.Cmd, func(), error) {
+	path, err := e.scriptPath(kb.Script, acct)
+	if err != nil {
+		return nil, nil, err
+	}
+	return exec.CommandContext(ctx, kb.Interpreter, path), func() {}, nil
+}
+
+// commandFile runs an external script file with its arguments
+func (e *Executor) commandFile(ctx context.Context, kb *config.Keybinding) (*exec.Cmd, func(), error) {
+	path := config.ExpandPath(kb.File, kb.Env)
 
 	// Check file exists
 	info, err := os.Stat(path)
 	if err != nil {
-		return fmt.Errorf("file not found: %w", err)
+		return nil, nil, fmt.Errorf("file not found: %w", err)
 	}
 
-	var cmd *exec.Cmd
-
 	// Check if executable
 	if info.Mode()&0o111 != 0 {
 		// Executable - run directly
-		cmd = exec.CommandContext(ctx, path)
-	} else {
-		// Not executable - run with sh
-		cmd = exec.CommandContext(ctx, "sh", path)
+		return exec.CommandContext(ctx, path, kb.Args...), func() {}, nil
 	}
 
-	if err := cmd.Start(); err != nil {
-		return fmt.Errorf("start file: %w", err)
+	// Not executable - run with the interpreter of its shebang, or sh
+	interpreter, err := shebang(path)
+	if err != nil {
+		return nil, nil, err
 	}
+	if len(interpreter) == 0 {
+		interpreter = []string{"sh"}
+	}
+	args := slices.Concat(interpreter[1:], []string{path}, kb.Args)
+	return exec.CommandContext(ctx, interpreter[0], args...), func() {}, nil
+}
 
-	e.trackCommand(kb.Name, cmd)
-
-	go func() {
-		if err := cmd.Wait(); err != nil {
-			return
-		}
-		e.untrackCommand(kb.Name)
-	}()
-
-	return nil
+// trackRun adds a run to the running map
+func (e *Executor) trackRun(name string, r *run) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.running[name] = append(e.running[name], r)
 }
 
-// trackCommand adds command to running map
-func (e *Executor) trackCommand(name string, cmd *exec.Cmd) {
+// untrackRun removes a run from the running map
+func (e *Executor) untrackRun(name string, r *run) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
-	e.running[name] = cmd
+
+	remaining := slices.DeleteFunc(e.running[name], func(other *run) bool { return other == r })
+	if len(remaining) == 0 {
+		delete(e.running, name)
+		return
+	}
+	e.running[name] = remaining
 }
 
-// untrackCommand removes command from running map
-func (e *Executor) untrackCommand(name string) {
+// runs returns the running runs of a keybinding
+func (e *Executor) runs(name string) []*run {
 	e.mu.Lock()
 	defer e.mu.Unlock()
-	delete(e.running, name)
+	return slices.Clone(e.running[name])
+}
+
+// Kill stops the running actions of a keybinding and returns how many
+// there were
+func (e *Executor) Kill(name string) int {
+	runs := e.runs(name)
+	e.stop(runs)
+	return len(runs)
 }
 
 // IsRunning checks if a keybinding's command is still running
 func (e *Executor) IsRunning(name string) bool {
 	e.mu.Lock()
 	defer e.mu.Unlock()
-	_, exists := e.running[name]
-	return exists
+	return len(e.running[name]) > 0
 }
 
+// Idle returns a channel that is closed once the started and queued
+// actions and their hooks have finished. Triggers executed while
+// waiting on it must come from those actions, as after a replay ends.
+func (e *Executor) Idle() <-chan struct{} {
+	idle := make(chan struct{})
+	go func() {
+		e.active.Wait()
+		close(idle)
+	}()
+	return idle
+}
+
+// Shutdown stops every running action and refuses new ones. Process groups
+// get SIGTERM and are killed if they have not exited after shutdownTimeout.
 func (e *Executor) Shutdown() error {
+	e.launch.Lock()
+	e.closed = true
+	e.launch.Unlock()
+
+	// Queued triggers are dropped
 	e.mu.Lock()
-	defer e.mu.Unlock()
+	clear(e.queued)
+	var running []*run
+	for _, runs := range e.running {
+		running = append(running, runs...)
+	}
+	e.mu.Unlock()
+
+	// Scripts are removed once their runs are gone
+	defer e.removeScripts()
 
-	runningCount := len(e.running)
-	if runningCount == 0 {
+	if len(running) == 0 {
 		return nil
 	}
+	fmt.Printf("Stopping %d running action(s)...\n", len(running))
 
 	var errs error
-	for _, cmd := range e.running {
+	for _, r := range running {
 		// Graceful termination
-		if err := cmd.Process.Signal(syscall.SIGTERM); err != nil {
-			errs = errors.Join(errs, err)
+		errs = errors.Join(errs, r.stop())
+	}
+
+	if !waitAll(running, shutdownTimeout) {
+		for _, r := range running {
+			if !r.exited() {
+				log.Printf("Warning: killing %s, still running after %s", r.name, shutdownTimeout)
+				r.signal(syscall.SIGKILL)
+			}
 		}
 	}
 
@@ -0,0 +1,260 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/glowfi/ghkd/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// waitIdle waits for the executor to finish its runs
+func waitIdle(t *testing.T, e *Executor) {
+	t.Helper()
+	select {
+	case <-e.Idle():
+	case <-time.After(5 * time.Second):
+		t.Fatal("expect runs to finish")
+	}
+}
+
+func TestExecutor_Concurrency(t *testing.T) {
+	tests := []struct {
+		name         string
+		concurrency  string
+		wantStatuses []string // history of the binding, oldest first
+		wantSerial   bool     // the runs didn't overlap
+	}{
+		{
+			name:         "should skip a press while running with single :POS",
+			concurrency:  config.ConcurrencySingle,
+			wantStatuses: []string{"exit 0", SuppressedSingle},
+		},
+		{
+			name:         "should stop and start again with restart :POS",
+			concurrency:  config.ConcurrencyRestart,
+			wantStatuses: []string{"killed by SIGTERM", "exit 0"},
+			wantSerial:   true,
+		},
+		{
+			name:         "should stop without starting again with toggle :POS",
+			concurrency:  config.ConcurrencyToggle,
+			wantStatuses: []string{"killed by SIGTERM"},
+		},
+		{
+			name:         "should run the press after the running one with queue :POS",
+			concurrency:  config.ConcurrencyQueue,
+			wantStatuses: []string{"exit 0", "exit 0"},
+			wantSerial:   true,
+		},
+		{
+			name:         "should run presses side by side with parallel :NEG",
+			concurrency:  config.ConcurrencyParallel,
+			wantStatuses: []string{"exit 0", "exit 0"},
+		},
+	}
+
+	for _, tt := range tests {
+		e := New()
+		kb := &config.Keybinding{
+			Name:        "Sleep",
+			Run:         config.Command{Line: "sleep 0.3"},
+			Concurrency: tt.concurrency,
+		}
+
+		for range 2 {
+			begin := time.Now()
+			require.NoError(t, e.Execute(context.Background(), kb, Trigger{}), tt.name)
+			// No policy waits for the running action in Execute
+			assert.Less(t, time.Since(begin), 100*time.Millisecond, tt.name)
+			time.Sleep(50 * time.Millisecond)
+		}
+		waitIdle(t, e)
+		assert.False(t, e.IsRunning("Sleep"), "expect Idle to wait for stopped actions")
+
+		entries := e.History("Sleep")
+		var statuses []string
+		for _, entry := range entries {
+			statuses = append(statuses, entry.Status)
+		}
+		assert.Equal(t, tt.wantStatuses, statuses, tt.name)
+
+		if tt.wantSerial && len(entries) == 2 {
+			firstEnd := entries[0].Time.Add(time.Duration(entries[0].DurationMs) * time.Millisecond)
+			assert.False(t, entries[1].Time.Before(firstEnd.Add(-time.Millisecond)), tt.name)
+		}
+		assert.NoError(t, e.Shutdown(), tt.name)
+	}
+}
+
+func TestExecutor_Queue(t *testing.T) {
+	tests := []struct {
+		name     string
+		triggers int
+		wantRuns int
+		wantFull int // triggers dropped as the queue was full
+	}{
+		{
+			name:     "should run every queued trigger :POS",
+			triggers: 3,
+			wantRuns: 3,
+		},
+		{
+			name:     "should drop triggers beyond the queue size :NEG",
+			triggers: maxQueued + 3,
+			wantRuns: maxQueued + 1,
+			wantFull: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		e := New()
+		kb := &config.Keybinding{
+			Name:        "Sleep",
+			Run:         config.Command{Line: "sleep 0.05"},
+			Concurrency: config.ConcurrencyQueue,
+		}
+		e.SetLimits(config.Limits{MaxSpawnsPerSecond: 100})
+
+		for range tt.triggers {
+			require.NoError(t, e.Execute(context.Background(), kb, Trigger{}), tt.name)
+		}
+		waitIdle(t, e)
+
+		var runs, full int
+		for _, entry := range e.History("Sleep") {
+			switch entry.Status {
+			case "exit 0":
+				runs++
+			case SuppressedQueue:
+				full++
+			}
+		}
+		assert.Equal(t, tt.wantRuns, runs, tt.name)
+		assert.Equal(t, tt.wantFull, full, tt.name)
+		assert.NoError(t, e.Shutdown(), tt.name)
+	}
+}
+
+func TestExecutor_Timeout(t *testing.T) {
+	tests := []struct {
+		name         string
+		command      string
+		wantStatus   string
+		wantDuration [2]time.Duration // bounds of the run's duration
+	}{
+		{
+			name:         "should stop an action that exits on SIGTERM :POS",
+			command:      "sleep 5",
+			wantStatus:   "timed out (killed by SIGTERM)",
+			wantDuration: [2]time.Duration{100 * time.Millisecond, killGrace},
+		},
+		{
+			name:         "should kill an action that ignores SIGTERM :POS",
+			command:      "trap '' TERM; sleep 5",
+			wantStatus:   "timed out (killed by SIGKILL)",
+			wantDuration: [2]time.Duration{100*time.Millisecond + killGrace, 5 * time.Second},
+		},
+		{
+			name:         "should not stop an action within its timeout :NEG",
+			command:      "true",
+			wantStatus:   "exit 0",
+			wantDuration: [2]time.Duration{0, 100 * time.Millisecond},
+		},
+	}
+
+	for _, tt := range tests {
+		e := New()
+		kb := &config.Keybinding{
+			Name:    "Slow",
+			Run:     config.Command{Line: tt.command},
+			Timeout: 100 * time.Millisecond,
+		}
+
+		require.NoError(t, e.Execute(context.Background(), kb, Trigger{}), tt.name)
+		waitIdle(t, e)
+
+		entries := e.History("Slow")
+		require.Len(t, entries, 1, tt.name)
+		assert.Equal(t, tt.wantStatus, entries[0].Status, tt.name)
+
+		duration := time.Duration(entries[0].DurationMs) * time.Millisecond
+		assert.GreaterOrEqual(t, duration, tt.wantDuration[0], tt.name)
+		assert.Less(t, duration, tt.wantDuration[1], tt.name)
+		assert.NoError(t, e.Shutdown(), tt.name)
+	}
+}
+
+// alive reports whether a process runs, zombies waiting to be reaped don't
+func alive(pid int) bool {
+	stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return false
+	}
+	fields := strings.Fields(string(stat[bytes.LastIndexByte(stat, ')')+1:]))
+	return len(fields) > 0 && fields[0] != "Z"
+}
+
+func TestExecutor_ProcessGroup(t *testing.T) {
+	tests := []struct {
+		name         string
+		command      string
+		stop         func(e *Executor) error
+		wantDuration time.Duration // minimum time the stop took
+	}{
+		{
+			name:    "should kill background children with the binding :POS",
+			command: "sleep 60 & echo $! > \"$PIDFILE\"; wait",
+			stop: func(e *Executor) error {
+				e.Kill("Background")
+				return e.Shutdown()
+			},
+		},
+		{
+			name:    "should stop background children on shutdown :POS",
+			command: "sleep 60 & echo $! > \"$PIDFILE\"; wait",
+			stop:    func(e *Executor) error { return e.Shutdown() },
+		},
+		{
+			name:         "should kill children ignoring SIGTERM after the shutdown timeout :NEG",
+			command:      "trap '' TERM; sleep 60 & echo $! > \"$PIDFILE\"; wait",
+			stop:         func(e *Executor) error { return e.Shutdown() },
+			wantDuration: shutdownTimeout,
+		},
+	}
+
+	for _, tt := range tests {
+		pidFile := filepath.Join(t.TempDir(), "pid")
+		e := New()
+		kb := &config.Keybinding{
+			Name: "Background",
+			Run:  config.Command{Line: tt.command},
+			Env:  map[string]string{"PIDFILE": pidFile},
+		}
+		require.NoError(t, e.Execute(context.Background(), kb, Trigger{}), tt.name)
+
+		var child int
+		require.Eventually(t, func() bool {
+			data, err := os.ReadFile(pidFile)
+			if err != nil {
+				return false
+			}
+			child, err = strconv.Atoi(strings.TrimSpace(string(data)))
+			return err == nil
+		}, 5*time.Second, 5*time.Millisecond, tt.name)
+		require.True(t, alive(child), tt.name)
+
+		begin := time.Now()
+		assert.NoError(t, tt.stop(e), tt.name)
+		assert.GreaterOrEqual(t, time.Since(begin), tt.wantDuration, tt.name)
+		assert.Eventually(t, func() bool { return !alive(child) }, 5*time.Second, 5*time.Millisecond, "expect the background child to be gone")
+	}
+}
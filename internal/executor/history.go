@@ -0,0 +1,122 @@
+package executor
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// historySize is how many runs the history keeps
+const historySize = 200
+
+// HistoryEntry is one run of a binding
+type HistoryEntry struct {
+	Time       time.Time `json:"time"` // When the run started
+	Binding    string    `json:"binding"`
+	Keys       string    `json:"keys"`
+	Device     string    `json:"device"`
+	PID        int       `json:"pid,omitempty"` // First process of the run
+	Running    bool      `json:"running,omitempty"`
+	Suppressed bool      `json:"suppressed,omitempty"` // The trigger started no run
+	ExitCode   int       `json:"exit_code"`
+	Signal     string    `json:"signal,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Status     string    `json:"status"` // "running", "exit 0", "killed by SIGTERM", "cooldown"
+	DurationMs int64     `json:"duration_ms"`
+}
+
+// history is a bounded log of runs, oldest first
+type history struct {
+	mu      sync.Mutex
+	entries []*HistoryEntry
+}
+
+// Statuses of triggers that were suppressed instead of starting a run
+const (
+	SuppressedCooldown = "cooldown"
+	SuppressedDebounce = "debounced"
+	SuppressedSingle   = "already running"
+	SuppressedSpawns   = "spawn limit"
+	SuppressedQueue    = "queue full"
+)
+
+// begin records a started run and returns its entry
+func (h *history) begin(name string, trig Trigger, started time.Time) *HistoryEntry {
+	entry := &HistoryEntry{
+		Time:    started,
+		Binding: name,
+		Keys:    trig.Keys,
+		Device:  trig.Device,
+		Running: true,
+		Status:  "running",
+	}
+	h.add(entry)
+	return entry
+}
+
+// suppress records a trigger that started no run, with the reason as its
+// status
+func (h *history) suppress(name string, trig Trigger, reason string) {
+	h.add(&HistoryEntry{
+		Time:       time.Now(),
+		Binding:    name,
+		Keys:       trig.Keys,
+		Device:     trig.Device,
+		Suppressed: true,
+		Status:     reason,
+	})
+}
+
+// add appends an entry, dropping the oldest beyond historySize. A dropped
+// entry may still be finished, it just isn't listed anymore.
+func (h *history) add(entry *HistoryEntry) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.entries = append(h.entries, entry)
+	if len(h.entries) > historySize {
+		h.entries = slices.Delete(h.entries, 0, len(h.entries)-historySize)
+	}
+}
+
+// finish fills in how a run ended
+func (h *history) finish(entry *HistoryEntry, status Status) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	entry.Running = false
+	entry.PID = status.PID
+	entry.ExitCode = status.ExitCode
+	entry.Signal = status.Signal
+	if status.Err != nil {
+		entry.Error = status.Err.Error()
+	}
+	entry.Status = status.String()
+	entry.DurationMs = status.Duration.Milliseconds()
+}
+
+// setPID records the first process of a running entry
+func (h *history) setPID(entry *HistoryEntry, pid int) {
+	if entry == nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	entry.PID = pid
+}
+
+// History returns the recorded runs, oldest first, of one binding or of
+// all when binding is empty (Thread-Safe)
+func (e *Executor) History(binding string) []HistoryEntry {
+	e.history.mu.Lock()
+	defer e.history.mu.Unlock()
+
+	entries := make([]HistoryEntry, 0, len(e.history.entries))
+	for _, entry := range e.history.entries {
+		if binding == "" || entry.Binding == binding {
+			entries = append(entries, *entry)
+		}
+	}
+	return entries
+}
@@ -0,0 +1,178 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/glowfi/ghkd/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistory_Size(t *testing.T) {
+	tests := []struct {
+		name      string
+		runs      int
+		wantLen   int
+		wantFirst string // binding of the oldest entry kept
+	}{
+		{
+			name:      "should keep every run below the limit :POS",
+			runs:      3,
+			wantLen:   3,
+			wantFirst: "run-0",
+		},
+		{
+			name:      "should drop the oldest runs beyond the limit :NEG",
+			runs:      historySize + 5,
+			wantLen:   historySize,
+			wantFirst: "run-5",
+		},
+	}
+
+	for _, tt := range tests {
+		e := New()
+		for i := range tt.runs {
+			e.history.begin(fmt.Sprintf("run-%d", i), Trigger{}, time.Now())
+		}
+
+		entries := e.History("")
+		require.Len(t, entries, tt.wantLen, tt.name)
+		assert.Equal(t, tt.wantFirst, entries[0].Binding, tt.name)
+	}
+}
+
+func TestHistory_Binding(t *testing.T) {
+	tests := []struct {
+		name     string
+		binding  string
+		wantKeys []string
+	}{
+		{
+			name:     "should list every binding without a filter :POS",
+			binding:  "",
+			wantKeys: []string{"super+a", "super+b", "super+c"},
+		},
+		{
+			name:     "should list only the runs of the binding :POS",
+			binding:  "A",
+			wantKeys: []string{"super+a", "super+c"},
+		},
+		{
+			name:     "should list nothing for an unknown binding :NEG",
+			binding:  "Missing",
+			wantKeys: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		e := New()
+		e.history.begin("A", Trigger{Keys: "super+a"}, time.Now())
+		e.history.begin("B", Trigger{Keys: "super+b"}, time.Now())
+		e.history.suppress("A", Trigger{Keys: "super+c"}, SuppressedCooldown)
+
+		var keys []string
+		for _, entry := range e.History(tt.binding) {
+			keys = append(keys, entry.Keys)
+		}
+		assert.Equal(t, tt.wantKeys, keys, tt.name)
+	}
+}
+
+func TestHistory_FinishEvicted(t *testing.T) {
+	tests := []struct {
+		name    string
+		evicted bool // the run is dropped before it finishes
+	}{
+		{
+			name:    "should fill in a listed run :POS",
+			evicted: false,
+		},
+		{
+			name:    "should finish a dropped run without touching the others :NEG",
+			evicted: true,
+		},
+	}
+
+	for _, tt := range tests {
+		e := New()
+		entry := e.history.begin("Slow", Trigger{}, time.Now())
+		if tt.evicted {
+			for range historySize {
+				e.history.begin("Fast", Trigger{}, time.Now())
+			}
+		}
+
+		e.history.finish(entry, Status{ExitCode: 1, Duration: time.Second})
+
+		entries := e.History("")
+		if tt.evicted {
+			require.Len(t, entries, historySize, tt.name)
+			for _, listed := range entries {
+				assert.Equal(t, "Fast", listed.Binding, tt.name)
+				assert.True(t, listed.Running, tt.name)
+			}
+			continue
+		}
+		require.Len(t, entries, 1, tt.name)
+		assert.False(t, entries[0].Running, tt.name)
+		assert.Equal(t, 1, entries[0].ExitCode, tt.name)
+		assert.Equal(t, int64(1000), entries[0].DurationMs, tt.name)
+	}
+}
+
+func TestHistory_Suppressed(t *testing.T) {
+	tests := []struct {
+		name         string
+		kb           config.Keybinding
+		perSecond    int
+		wantStatuses []string // history of the binding, oldest first
+	}{
+		{
+			name:         "should record a press during the cooldown :POS",
+			kb:           config.Keybinding{Cooldown: time.Minute},
+			wantStatuses: []string{"exit 0", SuppressedCooldown},
+		},
+		{
+			name:         "should run the first press and record the bounce with debounce :POS",
+			kb:           config.Keybinding{Debounce: 50 * time.Millisecond},
+			wantStatuses: []string{"exit 0", SuppressedDebounce},
+		},
+		{
+			name:         "should record a press over the spawn limit :POS",
+			kb:           config.Keybinding{Concurrency: config.ConcurrencyParallel},
+			perSecond:    1,
+			wantStatuses: []string{"exit 0", SuppressedSpawns},
+		},
+		{
+			name:         "should record nothing but runs without limits :NEG",
+			kb:           config.Keybinding{Concurrency: config.ConcurrencyParallel},
+			wantStatuses: []string{"exit 0", "exit 0"},
+		},
+	}
+
+	for _, tt := range tests {
+		e := New()
+		if tt.perSecond > 0 {
+			e.limits.perSecond = tt.perSecond
+		}
+		kb := tt.kb
+		kb.Name = "True"
+		kb.Run = config.Command{Line: "true"}
+
+		for range 2 {
+			require.NoError(t, e.Execute(context.Background(), &kb, Trigger{}), tt.name)
+		}
+		waitIdle(t, e)
+
+		var statuses []string
+		for _, entry := range e.History("True") {
+			statuses = append(statuses, entry.Status)
+			assert.Equal(t, entry.PID == 0, entry.Suppressed, tt.name)
+		}
+		assert.Equal(t, tt.wantStatuses, statuses, tt.name)
+		assert.NoError(t, e.Shutdown(), tt.name)
+	}
+}
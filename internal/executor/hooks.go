@@ -0,0 +1,81 @@
+package executor
+
+import (
+	"context"
+	"log"
+	"maps"
+
+	"github.com/glowfi/ghkd/internal/config"
+)
+
+// SetErrorHook replaces the hook run after any binding fails (Thread-Safe)
+func (e *Executor) SetErrorHook(hook config.Command) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.onError = hook
+}
+
+// runHooks runs the binding's on_success or on_failure hook and, if it
+// failed, the global on_error hook. Runs stopped by toggle, restart or kill
+// have no hooks; a timeout counts as a failure.
+func (e *Executor) runHooks(ctx context.Context, r *run, kb *config.Keybinding, status Status) {
+	r.mu.Lock()
+	stopped := r.stopped && !r.timedOut
+	r.mu.Unlock()
+	if stopped || ctx.Err() != nil {
+		return
+	}
+
+	if !status.Failed() {
+		e.runHook(ctx, kb, "on_success", kb.OnSuccess, status)
+		return
+	}
+
+	e.mu.Lock()
+	onError := e.onError
+	e.mu.Unlock()
+
+	e.runHook(ctx, kb, "on_failure", kb.OnFailure, status)
+	e.runHook(ctx, kb, "on_error", onError, status)
+}
+
+// runHook runs a hook command with the binding's settings and the GHKD_*
+// variables of the run. It is tracked apart from the binding, so it
+// doesn't count for its concurrency, but is stopped on shutdown.
+func (e *Executor) runHook(ctx context.Context, kb *config.Keybinding, name string, hook config.Command, status Status) {
+	if hook.IsZero() {
+		return
+	}
+
+	hookKb := *kb
+	hookKb.Run = hook
+	hookKb.File, hookKb.Args = "", nil
+	hookKb.Script, hookKb.Interpreter = "", ""
+	hookKb.Actions, hookKb.Action = nil, ""
+	hookKb.Env = maps.Clone(kb.Env)
+	if hookKb.Env == nil {
+		hookKb.Env = map[string]string{}
+	}
+	maps.Copy(hookKb.Env, status.env())
+
+	r := newRun(kb.Name + " " + name)
+
+	// Checked under launch so Shutdown either sees the hook or it doesn't
+	// start
+	e.launch.Lock()
+	if e.closed {
+		e.launch.Unlock()
+		return
+	}
+	e.trackRun(r.name, r)
+	e.launch.Unlock()
+
+	defer func() {
+		e.untrackRun(r.name, r)
+		close(r.done)
+	}()
+
+	if result := e.runProcess(ctx, r, &hookKb); result.Failed() {
+		log.Printf("Error: %s: %s: %s", kb.Name, name, result)
+	}
+}
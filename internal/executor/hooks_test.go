@@ -0,0 +1,134 @@
+package executor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/glowfi/ghkd/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHooks_RunHooks(t *testing.T) {
+	hook := func(name string) config.Command {
+		return config.Command{Line: `echo "` + name + ` $GHKD_EXIT_CODE $GHKD_EXIT_SIGNAL $GHKD_STATUS" >> "$OUT"`}
+	}
+
+	tests := []struct {
+		name      string
+		command   string
+		timeout   time.Duration
+		kill      bool // the run is stopped with kill
+		wantHooks []string
+	}{
+		{
+			name:      "should run on_success after exit 0 :POS",
+			command:   "true",
+			wantHooks: []string{"success 0  exit 0"},
+		},
+		{
+			name:      "should run on_failure and on_error after exit 3 :POS",
+			command:   "exit 3",
+			wantHooks: []string{"error 3  exit 3", "failure 3  exit 3"},
+		},
+		{
+			name:      "should run the failure hooks after a timeout :POS",
+			command:   "sleep 5",
+			timeout:   50 * time.Millisecond,
+			wantHooks: []string{"error -1 SIGTERM timed out (killed by SIGTERM)", "failure -1 SIGTERM timed out (killed by SIGTERM)"},
+		},
+		{
+			name:    "should run no hooks for a stopped run :NEG",
+			command: "sleep 5",
+			kill:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		out := filepath.Join(t.TempDir(), "out")
+		e := New()
+		e.SetErrorHook(hook("error"))
+		kb := &config.Keybinding{
+			Name:      "Hooked",
+			Run:       config.Command{Line: tt.command},
+			Timeout:   tt.timeout,
+			Env:       map[string]string{"OUT": out},
+			OnSuccess: hook("success"),
+			OnFailure: hook("failure"),
+		}
+
+		require.NoError(t, e.Execute(context.Background(), kb, Trigger{}), tt.name)
+		if tt.kill {
+			require.Eventually(t, func() bool { return e.IsRunning("Hooked") }, time.Second, 5*time.Millisecond, tt.name)
+			e.Kill("Hooked")
+		}
+		waitIdle(t, e)
+
+		var hooks []string
+		if data, err := os.ReadFile(out); err == nil {
+			hooks = strings.Split(strings.TrimSpace(string(data)), "\n")
+			slices.Sort(hooks) // on_failure and on_error may finish in any order
+		}
+		assert.Equal(t, tt.wantHooks, hooks, tt.name)
+		assert.NoError(t, e.Shutdown(), tt.name)
+	}
+}
+
+func TestHooks_Status(t *testing.T) {
+	tests := []struct {
+		name       string
+		command    string
+		wantStatus string
+		wantCode   int
+		wantSignal string
+	}{
+		{
+			name:       "should report exit 0 :POS",
+			command:    "true",
+			wantStatus: "exit 0",
+			wantCode:   0,
+		},
+		{
+			name:       "should report the exit code :POS",
+			command:    "exit 3",
+			wantStatus: "exit 3",
+			wantCode:   3,
+		},
+		{
+			name:       "should report the signal that killed the action :NEG",
+			command:    "kill -USR1 $$",
+			wantStatus: "killed by SIGUSR1",
+			wantCode:   -1,
+			wantSignal: "SIGUSR1",
+		},
+		{
+			name:       "should report a program that can't start :NEG",
+			command:    "",
+			wantStatus: "error: no action defined for keybinding: Status",
+			wantCode:   -1,
+		},
+	}
+
+	for _, tt := range tests {
+		e := New()
+		kb := &config.Keybinding{Name: "Status"}
+		if tt.command != "" {
+			kb.Run = config.Command{Line: tt.command}
+		}
+
+		require.NoError(t, e.Execute(context.Background(), kb, Trigger{}), tt.name)
+		waitIdle(t, e)
+
+		entries := e.History("Status")
+		require.Len(t, entries, 1, tt.name)
+		assert.Equal(t, tt.wantStatus, entries[0].Status, tt.name)
+		assert.Equal(t, tt.wantCode, entries[0].ExitCode, tt.name)
+		assert.Equal(t, tt.wantSignal, entries[0].Signal, tt.name)
+		assert.NoError(t, e.Shutdown(), tt.name)
+	}
+}
@@ -0,0 +1,208 @@
+package executor
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/glowfi/ghkd/internal/config"
+)
+
+// envImport holds the session variables imported into actions
+type envImport struct {
+	mu     sync.Mutex
+	config config.ImportEnv
+	sent   map[uint32]map[string]string // set with 'ghkd setenv', by sender uid
+	failed string                       // last import error, logged once
+}
+
+// names returns the variables that may be imported. Must be called with
+// i.mu held.
+func (i *envImport) names() []string {
+	if len(i.config.Vars) == 0 {
+		return config.DefaultImportVars
+	}
+	return i.config.Vars
+}
+
+// vars reads the configured sources and returns the variables imported
+// into an action running as uid. A ~ in the file is uid's home, the
+// daemon's when home is empty. Variables sent with setenv win over the
+// file and the process, and only the ones uid sent itself are used.
+func (i *envImport) vars(uid uint32, home string) map[string]string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	names := i.names()
+	vars := map[string]string{}
+	var errs []string
+
+	if i.config.File != "" {
+		var env map[string]string
+		if home != "" {
+			env = map[string]string{"HOME": home}
+		}
+		fileEnv, err := config.ReadEnvFile(config.ExpandPath(i.config.File, env))
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+		copyVars(vars, fileEnv, names)
+	}
+
+	if i.config.Process != "" {
+		procEnv, err := i.processVars(uid)
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+		copyVars(vars, procEnv, names)
+	}
+
+	copyVars(vars, i.sent[uid], names)
+
+	// Sources are read before every action, don't repeat the same warning
+	if failed := strings.Join(errs, "; "); failed != i.failed {
+		if failed != "" {
+			log.Printf("Warning: import env: %s", failed)
+		}
+		i.failed = failed
+	}
+
+	return vars
+}
+
+// copyVars copies the named variables from src to dst
+func copyVars(dst, src map[string]string, names []string) {
+	for _, name := range names {
+		if value, ok := src[name]; ok {
+			dst[name] = value
+		}
+	}
+}
+
+// processVars reads the environment of the configured process. It must be
+// owned by the import_env user, or by uid, the user of the action, so an
+// action never picks up what another user's process set. Must be called
+// with i.mu held.
+func (i *envImport) processVars(uid uint32) (map[string]string, error) {
+	owner := uid
+	if i.config.User != "" {
+		u, err := config.LookupUser(i.config.User)
+		if err != nil {
+			return nil, err
+		}
+		id, err := strconv.ParseUint(u.Uid, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("user %s: uid %s: %w", i.config.User, u.Uid, err)
+		}
+		owner = uint32(id)
+	}
+	return processEnv(i.config.Process, owner)
+}
+
+// processEnv returns the environment of the newest process with the given
+// command name that owner runs
+func processEnv(name string, owner uint32) (map[string]string, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+
+	newest := 0
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		comm, err := os.ReadFile(filepath.Join("/proc", entry.Name(), "comm"))
+		if err != nil || strings.TrimSpace(string(comm)) != name {
+			continue
+		}
+		info, err := os.Stat(filepath.Join("/proc", entry.Name()))
+		if err != nil {
+			continue
+		}
+		if stat, ok := info.Sys().(*syscall.Stat_t); !ok || stat.Uid != owner {
+			continue
+		}
+		newest = max(newest, pid)
+	}
+	if newest == 0 {
+		return nil, fmt.Errorf("no %s process of uid %d found", name, owner)
+	}
+
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/environ", newest))
+	if err != nil {
+		return nil, err
+	}
+
+	env := map[string]string{}
+	for entry := range bytes.SplitSeq(data, []byte{0}) {
+		if key, value, found := strings.Cut(string(entry), "="); found {
+			env[key] = value
+		}
+	}
+	return env, nil
+}
+
+// UpdateImport replaces the import sources (Thread-Safe)
+func (e *Executor) UpdateImport(cfg config.ImportEnv) {
+	e.imports.mu.Lock()
+	defer e.imports.mu.Unlock()
+	e.imports.config = cfg
+	e.imports.failed = ""
+}
+
+// SetEnv imports variables sent by 'ghkd setenv' from the user uid into
+// the actions that run as that user. Variables import_env doesn't list
+// are not imported and returned. An empty value removes the variable from
+// the imports (Thread-Safe).
+func (e *Executor) SetEnv(uid uint32, vars map[string]string) (ignored []string) {
+	e.imports.mu.Lock()
+	defer e.imports.mu.Unlock()
+
+	names := e.imports.names()
+	sent := e.imports.sent[uid]
+	if sent == nil {
+		sent = map[string]string{}
+		e.imports.sent[uid] = sent
+	}
+
+	for key, value := range vars {
+		switch {
+		case !slices.Contains(names, key):
+			ignored = append(ignored, key)
+		case value == "":
+			delete(sent, key)
+		default:
+			sent[key] = value
+		}
+	}
+	slices.Sort(ignored)
+	return ignored
+}
+
+// withImports returns a copy of the keybinding with the variables imported
+// for the account, nil for the daemon's user, under its own
+func (e *Executor) withImports(kb *config.Keybinding, acct *account) *config.Keybinding {
+	var home string
+	if acct != nil {
+		home = acct.home
+	}
+	vars := e.imports.vars(acct.id(), home)
+	if len(vars) == 0 {
+		return kb
+	}
+
+	resolved := *kb
+	maps.Copy(vars, kb.Env)
+	resolved.Env = vars
+	return &resolved
+}
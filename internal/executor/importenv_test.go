@@ -0,0 +1,126 @@
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/glowfi/ghkd/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImportEnv_SetEnv(t *testing.T) {
+	tests := []struct {
+		name        string
+		cfg         config.ImportEnv
+		sender      uint32
+		sent        map[string]string
+		actionUID   uint32
+		wantIgnored []string
+		want        map[string]string
+	}{
+		{
+			name:      "should import listed variables for the sender :POS",
+			cfg:       config.ImportEnv{Vars: []string{"WAYLAND_DISPLAY"}},
+			sender:    1000,
+			sent:      map[string]string{"WAYLAND_DISPLAY": "wayland-1"},
+			actionUID: 1000,
+			want:      map[string]string{"WAYLAND_DISPLAY": "wayland-1"},
+		},
+		{
+			name:        "should ignore variables import_env doesn't list :NEG",
+			cfg:         config.ImportEnv{Vars: []string{"WAYLAND_DISPLAY"}},
+			sender:      1000,
+			sent:        map[string]string{"LD_PRELOAD": "/tmp/evil.so", "PATH": "/tmp"},
+			actionUID:   1000,
+			wantIgnored: []string{"LD_PRELOAD", "PATH"},
+			want:        map[string]string{},
+		},
+		{
+			name:      "should not import variables into another user's actions :NEG",
+			cfg:       config.ImportEnv{},
+			sender:    1000,
+			sent:      map[string]string{"DISPLAY": ":1"},
+			actionUID: 0,
+			want:      map[string]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		e := New()
+		e.UpdateImport(tt.cfg)
+
+		ignored := e.SetEnv(tt.sender, tt.sent)
+		assert.Equal(t, tt.wantIgnored, ignored, tt.name)
+		assert.Equal(t, tt.want, e.imports.vars(tt.actionUID, ""), tt.name)
+	}
+}
+
+func TestImportEnv_ProcessEnv(t *testing.T) {
+	comm, err := os.ReadFile("/proc/self/comm")
+	assert.NoError(t, err, "expect own command name")
+	self := strings.TrimSpace(string(comm))
+
+	tests := []struct {
+		name    string
+		owner   uint32
+		wantErr bool
+	}{
+		{
+			name:  "should read a process of the owner :POS",
+			owner: uint32(os.Geteuid()),
+		},
+		{
+			name:    "should skip processes of other users :NEG",
+			owner:   uint32(os.Geteuid()) + 1,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		env, err := processEnv(self, tt.owner)
+		if tt.wantErr {
+			assert.Error(t, err, tt.name)
+			continue
+		}
+		assert.NoError(t, err, tt.name)
+		assert.Equal(t, os.Getenv("PATH"), env["PATH"], tt.name)
+	}
+}
+
+func TestImportEnv_File(t *testing.T) {
+	daemonHome := t.TempDir()
+	userHome := t.TempDir()
+	t.Setenv("HOME", daemonHome)
+
+	for home, display := range map[string]string{daemonHome: ":0", userHome: ":1"} {
+		dir := filepath.Join(home, ".cache", "ghkd")
+		require.NoError(t, os.MkdirAll(dir, 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "session.env"), []byte("DISPLAY="+display+"\n"), 0o644))
+	}
+
+	tests := []struct {
+		name string
+		home string // home of the action's user, empty for the daemon's
+		want map[string]string
+	}{
+		{
+			name: "should read the file in the home of the action's user :POS",
+			home: userHome,
+			want: map[string]string{"DISPLAY": ":1"},
+		},
+		{
+			name: "should read the file in the daemon's home for its own actions :POS",
+			home: "",
+			want: map[string]string{"DISPLAY": ":0"},
+		},
+	}
+
+	for _, tt := range tests {
+		e := New()
+		e.UpdateImport(config.ImportEnv{File: "~/.cache/ghkd/session.env"})
+		assert.Equal(t, tt.want, e.imports.vars(1000, tt.home), tt.name)
+	}
+}
@@ -0,0 +1,91 @@
+package executor
+
+import (
+	"sync"
+	"time"
+
+	"github.com/glowfi/ghkd/internal/config"
+)
+
+// limiter suppresses triggers that come too fast: per binding with
+// cooldown and debounce, and for all bindings together with the spawn limit
+type limiter struct {
+	mu          sync.Mutex
+	perSecond   int                  // max runs started per second
+	starts      []time.Time          // runs started in the last second
+	lastStart   map[string]time.Time // last run start of each binding
+	lastTrigger map[string]time.Time // last trigger of each debounced binding
+}
+
+func newLimiter() limiter {
+	return limiter{
+		perSecond:   config.DefaultMaxSpawnsPerSecond,
+		lastStart:   make(map[string]time.Time),
+		lastTrigger: make(map[string]time.Time),
+	}
+}
+
+// cooldownLeft returns how long the binding's cooldown still lasts
+func (l *limiter) cooldownLeft(kb *config.Keybinding, now time.Time) time.Duration {
+	if kb.Cooldown <= 0 {
+		return 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	last, found := l.lastStart[kb.Name]
+	if !found {
+		return 0
+	}
+	return max(kb.Cooldown-now.Sub(last), 0)
+}
+
+// bouncing records a trigger of the binding and reports whether it came
+// within the debounce interval of the previous one. Every trigger restarts
+// the interval, so a burst only lets its first trigger through.
+func (l *limiter) bouncing(kb *config.Keybinding, now time.Time) bool {
+	if kb.Debounce <= 0 {
+		return false
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	last, found := l.lastTrigger[kb.Name]
+	l.lastTrigger[kb.Name] = now
+	return found && now.Sub(last) < kb.Debounce
+}
+
+// allowStart records a run start of the binding, unless the spawn limit
+// was reached in the last second
+func (l *limiter) allowStart(name string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	recent := l.starts[:0]
+	for _, start := range l.starts {
+		if now.Sub(start) < time.Second {
+			recent = append(recent, start)
+		}
+	}
+	l.starts = recent
+
+	if len(l.starts) >= l.perSecond {
+		return false
+	}
+	l.starts = append(l.starts, now)
+	l.lastStart[name] = now
+	return true
+}
+
+// SetLimits replaces the global limits (Thread-Safe)
+func (e *Executor) SetLimits(cfg config.Limits) {
+	e.limits.mu.Lock()
+	defer e.limits.mu.Unlock()
+
+	e.limits.perSecond = cfg.MaxSpawnsPerSecond
+	if e.limits.perSecond == 0 {
+		e.limits.perSecond = config.DefaultMaxSpawnsPerSecond
+	}
+}
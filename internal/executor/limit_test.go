@@ -0,0 +1,61 @@
+package executor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/glowfi/ghkd/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiter_Bouncing(t *testing.T) {
+	tests := []struct {
+		name     string
+		debounce time.Duration
+		triggers []time.Duration // offsets from the first trigger
+		want     []bool
+	}{
+		{
+			name:     "should let a single press through at once :POS",
+			debounce: 300 * time.Millisecond,
+			triggers: []time.Duration{0},
+			want:     []bool{false},
+		},
+		{
+			name:     "should suppress presses within the interval :POS",
+			debounce: 300 * time.Millisecond,
+			triggers: []time.Duration{0, 100 * time.Millisecond, 200 * time.Millisecond},
+			want:     []bool{false, true, true},
+		},
+		{
+			name:     "should restart the interval on every suppressed press :POS",
+			debounce: 300 * time.Millisecond,
+			triggers: []time.Duration{0, 200 * time.Millisecond, 400 * time.Millisecond, 600 * time.Millisecond},
+			want:     []bool{false, true, true, true},
+		},
+		{
+			name:     "should let a press through once the burst is over :POS",
+			debounce: 300 * time.Millisecond,
+			triggers: []time.Duration{0, 200 * time.Millisecond, 500 * time.Millisecond},
+			want:     []bool{false, true, false},
+		},
+		{
+			name:     "should never suppress without debounce :NEG",
+			debounce: 0,
+			triggers: []time.Duration{0, 0, time.Millisecond},
+			want:     []bool{false, false, false},
+		},
+	}
+
+	start := time.Now()
+	for _, tt := range tests {
+		l := newLimiter()
+		kb := &config.Keybinding{Name: "Switch", Debounce: tt.debounce}
+
+		var got []bool
+		for _, offset := range tt.triggers {
+			got = append(got, l.bouncing(kb, start.Add(offset)))
+		}
+		assert.Equal(t, tt.want, got, tt.name)
+	}
+}
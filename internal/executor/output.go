@@ -0,0 +1,286 @@
+package executor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/glowfi/ghkd/internal/config"
+)
+
+const (
+	// maxLogSize is the size at which a binding's log is rotated
+	maxLogSize = 1 << 20
+
+	// runHeaderPrefix starts the line written to a log before each run
+	runHeaderPrefix = "=== "
+)
+
+// LogDir returns the directory of binding logs, $XDG_STATE_HOME/ghkd or
+// ~/.local/state/ghkd
+func LogDir() (string, error) {
+	if state := os.Getenv("XDG_STATE_HOME"); state != "" {
+		return filepath.Join(state, "ghkd"), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "state", "ghkd"), nil
+}
+
+// LogPath returns the log file of a binding
+func LogPath(name string) (string, error) {
+	dir, err := LogDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, logFileName(name)), nil
+}
+
+// logFileName turns a binding name into a file name: "Open Alacritty"
+// becomes "open-alacritty-<hash>.log". The hash of the exact name keeps
+// names that read the same, like "open-alacritty", apart.
+func logFileName(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '_', r == '.':
+			b.WriteRune(r)
+		default:
+			b.WriteRune('-')
+		}
+	}
+	sum := sha256.Sum256([]byte(name))
+	return b.String() + "-" + hex.EncodeToString(sum[:4]) + ".log"
+}
+
+// attachOutput connects the stdout and stderr of an action to its output
+// destination. Logs of actions run as acct go to its state directory. The
+// returned func closes what was opened for it.
+func attachOutput(cmd *exec.Cmd, kb *config.Keybinding, acct *account) (func(), error) {
+	switch kb.Output {
+	case config.OutputInherit:
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		return func() {}, nil
+
+	case config.OutputLog:
+		file, err := openLog(kb.Name, acct)
+		if err != nil {
+			return nil, fmt.Errorf("open log: %w", err)
+		}
+		cmd.Stdout = file
+		cmd.Stderr = file
+		return func() { file.Close() }, nil
+
+	default:
+		// A nil stdout and stderr are connected to the null device
+		return func() {}, nil
+	}
+}
+
+// openLog opens a binding's log for a new run, in the state directory of
+// acct or of the daemon's user. A log larger than maxLogSize is moved to
+// <name>.log.1 first, replacing the previous one.
+func openLog(name string, acct *account) (*os.File, error) {
+	var (
+		file *os.File
+		err  error
+	)
+	if acct != nil {
+		file, err = openAccountLog(logFileName(name), acct)
+	} else {
+		file, err = openOwnLog(name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	header := fmt.Sprintf("%s%s %s\n", runHeaderPrefix, time.Now().Format(time.DateTime), name)
+	if _, err := file.WriteString(header); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+// openOwnLog opens a log in the daemon user's LogDir
+func openOwnLog(name string) (*os.File, error) {
+	path, err := LogPath(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+
+	if info, err := os.Stat(path); err == nil && info.Size() >= maxLogSize {
+		if err := os.Rename(path, path+".1"); err != nil {
+			return nil, err
+		}
+	}
+
+	return os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+}
+
+// accountLogDirs lead from an account's home to the directory LogDir
+// returns for it
+var accountLogDirs = []string{".local", "state", "ghkd"}
+
+// openAccountLog opens a log in ~/.local/state/ghkd of an account, so
+// ghkd logs run by that user finds it. The daemon is root here and the
+// account owns the path, so each step is taken from the open parent
+// without following symlinks, and anything the account doesn't own is
+// refused.
+func openAccountLog(file string, acct *account) (*os.File, error) {
+	dir, err := syscall.Open(acct.home, syscall.O_RDONLY|syscall.O_DIRECTORY|syscall.O_NOFOLLOW|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", acct.home, err)
+	}
+	if _, err := ownedStat(dir, acct); err != nil {
+		syscall.Close(dir)
+		return nil, fmt.Errorf("%s: %w", acct.home, err)
+	}
+
+	path := acct.home
+	for _, name := range accountLogDirs {
+		path = filepath.Join(path, name)
+		next, err := accountDir(dir, name, acct)
+		syscall.Close(dir)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		dir = next
+	}
+	defer syscall.Close(dir)
+
+	path = filepath.Join(path, file)
+	fd, err := reuseLog(dir, file, acct)
+	if err == nil && fd < 0 {
+		fd, err = syscall.Openat(dir, file, syscall.O_WRONLY|syscall.O_APPEND|syscall.O_CREAT|syscall.O_EXCL|syscall.O_NOFOLLOW|syscall.O_CLOEXEC, 0o600)
+		if err == nil {
+			if err = syscall.Fchown(fd, int(acct.uid), int(acct.gid)); err != nil {
+				syscall.Close(fd)
+			}
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return os.NewFile(uintptr(fd), path), nil
+}
+
+// reuseLog opens the existing log of an account for appending. It returns
+// -1 when there is none, or when it was full and has been rotated.
+func reuseLog(dir int, file string, acct *account) (int, error) {
+	fd, err := syscall.Openat(dir, file, syscall.O_WRONLY|syscall.O_APPEND|syscall.O_NOFOLLOW|syscall.O_CLOEXEC, 0)
+	if errors.Is(err, syscall.ENOENT) {
+		return -1, nil
+	}
+	if err != nil {
+		return -1, err
+	}
+
+	stat, err := ownedStat(fd, acct)
+	if err == nil && stat.Size < maxLogSize {
+		return fd, nil
+	}
+	syscall.Close(fd)
+	if err != nil {
+		return -1, err
+	}
+	return -1, syscall.Renameat(dir, file, dir, file+".1")
+}
+
+// accountDir opens the directory name below parent, creating it for the
+// account if it is missing
+func accountDir(parent int, name string, acct *account) (int, error) {
+	err := syscall.Mkdirat(parent, name, 0o700)
+	created := err == nil
+	if err != nil && !errors.Is(err, syscall.EEXIST) {
+		return -1, err
+	}
+
+	fd, err := syscall.Openat(parent, name, syscall.O_RDONLY|syscall.O_DIRECTORY|syscall.O_NOFOLLOW|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return -1, err
+	}
+	if created {
+		err = syscall.Fchown(fd, int(acct.uid), int(acct.gid))
+	}
+	if err == nil {
+		_, err = ownedStat(fd, acct)
+	}
+	if err != nil {
+		syscall.Close(fd)
+		return -1, err
+	}
+	return fd, nil
+}
+
+// ownedStat returns the status of an open file, which must belong to the
+// account
+func ownedStat(fd int, acct *account) (syscall.Stat_t, error) {
+	var stat syscall.Stat_t
+	if err := syscall.Fstat(fd, &stat); err != nil {
+		return stat, err
+	}
+	if stat.Uid != acct.uid {
+		return stat, fmt.Errorf("not owned by %s", acct.name)
+	}
+	return stat, nil
+}
+
+// RecentRuns returns the output of the last n runs of a binding, oldest
+// first. It returns fs.ErrNotExist when the binding has no log.
+func RecentRuns(name string, n int) ([]string, error) {
+	path, err := LogPath(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var data []byte
+	for _, file := range []string{path + ".1", path} {
+		content, err := os.ReadFile(file)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, content...)
+	}
+	if data == nil {
+		return nil, fs.ErrNotExist
+	}
+
+	var runs []string
+	var current strings.Builder
+	for _, line := range strings.SplitAfter(string(data), "\n") {
+		if strings.HasPrefix(line, runHeaderPrefix) && current.Len() > 0 {
+			runs = append(runs, current.String())
+			current.Reset()
+		}
+		current.WriteString(line)
+	}
+	if current.Len() > 0 {
+		runs = append(runs, current.String())
+	}
+
+	if len(runs) > n {
+		runs = runs[len(runs)-n:]
+	}
+	return runs, nil
+}
@@ -0,0 +1,109 @@
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutput_LogFileName(t *testing.T) {
+	tests := []struct {
+		name    string
+		binding string
+		other   string
+		want    string
+	}{
+		{
+			name:    "should sanitize the binding name :POS",
+			binding: "Open Alacritty",
+			other:   "open-alacritty",
+			want:    "open-alacritty-",
+		},
+		{
+			name:    "should keep names that differ in case apart :NEG",
+			binding: "Backup",
+			other:   "backup",
+			want:    "backup-",
+		},
+	}
+
+	for _, tt := range tests {
+		got := logFileName(tt.binding)
+		assert.Regexp(t, "^"+tt.want+"[0-9a-f]{8}\\.log$", got, tt.name)
+		assert.NotEqual(t, logFileName(tt.other), got, tt.name)
+	}
+}
+
+func TestOutput_OpenAccountLog(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("needs root to write logs for another account")
+	}
+
+	const uid, gid = 65534, 65534
+	stateDir := filepath.Join(".local", "state", "ghkd")
+
+	tests := []struct {
+		name    string
+		setup   func(home string)
+		wantErr bool
+	}{
+		{
+			name:  "should create the log in the account's state dir :POS",
+			setup: func(home string) {},
+		},
+		{
+			name: "should append to the account's existing log :POS",
+			setup: func(home string) {
+				dir := filepath.Join(home, stateDir)
+				require.NoError(t, os.MkdirAll(dir, 0o700))
+				require.NoError(t, os.WriteFile(filepath.Join(dir, "test.log"), []byte("old\n"), 0o600))
+				for _, path := range []string{".local", filepath.Join(".local", "state"), stateDir, filepath.Join(stateDir, "test.log")} {
+					require.NoError(t, os.Chown(filepath.Join(home, path), uid, gid))
+				}
+			},
+		},
+		{
+			name: "should refuse a symlinked state dir :NEG",
+			setup: func(home string) {
+				require.NoError(t, os.Symlink(t.TempDir(), filepath.Join(home, ".local")))
+			},
+			wantErr: true,
+		},
+		{
+			name: "should refuse a log the account doesn't own :NEG",
+			setup: func(home string) {
+				dir := filepath.Join(home, stateDir)
+				require.NoError(t, os.MkdirAll(dir, 0o700))
+				require.NoError(t, os.WriteFile(filepath.Join(dir, "test.log"), nil, 0o600))
+				for _, path := range []string{".local", filepath.Join(".local", "state"), stateDir} {
+					require.NoError(t, os.Chown(filepath.Join(home, path), uid, gid))
+				}
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		home := t.TempDir()
+		require.NoError(t, os.Chown(home, uid, gid))
+		tt.setup(home)
+
+		acct := &account{name: "nobody", uid: uid, gid: gid, home: home}
+		file, err := openAccountLog("test.log", acct)
+		if tt.wantErr {
+			assert.Error(t, err, tt.name)
+			continue
+		}
+		require.NoError(t, err, tt.name)
+		file.Close()
+
+		info, err := os.Stat(filepath.Join(home, stateDir, "test.log"))
+		require.NoError(t, err, tt.name)
+		assert.Equal(t, uint32(uid), info.Sys().(*syscall.Stat_t).Uid, tt.name)
+		assert.Equal(t, os.FileMode(0o600), info.Mode().Perm(), tt.name)
+	}
+}
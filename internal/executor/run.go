@@ -0,0 +1,269 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os/exec"
+	"slices"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/glowfi/ghkd/internal/config"
+)
+
+// run is one execution of a binding's action. A pipeline of steps starts
+// several processes over its lifetime, each in its own process group.
+type run struct {
+	name    string        // keybinding name
+	done    chan struct{} // closed once every step has finished
+	started time.Time
+
+	mu       sync.Mutex
+	procs    []*exec.Cmd // processes currently running
+	pid      int         // first process started
+	stopped  bool        // no further steps are started
+	timedOut bool        // stopped by the binding's timeout
+
+	entry *HistoryEntry // nil for hooks
+}
+
+func newRun(name string) *run {
+	return &run{name: name, done: make(chan struct{}), started: time.Now()}
+}
+
+// exited reports whether the run has finished
+func (r *run) exited() bool {
+	select {
+	case <-r.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// isStopped reports whether the run was stopped
+func (r *run) isStopped() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.stopped
+}
+
+// signal sends a signal to the process group of every running process
+func (r *run) signal(sig syscall.Signal) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var errs error
+	for _, cmd := range r.procs {
+		// The group may be gone already
+		if err := syscall.Kill(-cmd.Process.Pid, sig); err != nil && !errors.Is(err, syscall.ESRCH) {
+			errs = errors.Join(errs, err)
+		}
+	}
+	return errs
+}
+
+// stop keeps further steps from starting and sends SIGTERM to the running
+// ones
+func (r *run) stop() error {
+	r.mu.Lock()
+	r.stopped = true
+	r.mu.Unlock()
+	return r.signal(syscall.SIGTERM)
+}
+
+// terminate stops the run and sends SIGKILL if it has not finished after
+// killGrace. It reports whether SIGKILL was needed.
+func (r *run) terminate() (killed bool) {
+	if r.exited() {
+		return false
+	}
+	r.stop()
+
+	select {
+	case <-r.done:
+		return false
+	case <-time.After(killGrace):
+		r.signal(syscall.SIGKILL)
+		<-r.done
+		return true
+	}
+}
+
+// waitAll waits for runs to finish and reports whether they all did before
+// the timeout
+func waitAll(runs []*run, timeout time.Duration) bool {
+	deadline := time.After(timeout)
+	for _, r := range runs {
+		select {
+		case <-r.done:
+		case <-deadline:
+			return false
+		}
+	}
+	return true
+}
+
+// runSteps runs the steps of a binding in order. A failed step ends the
+// run unless it has continue_on_error. It returns the status of the step
+// that ended the run, with the run's first PID and whole duration.
+func (e *Executor) runSteps(ctx context.Context, r *run, kb *config.Keybinding) Status {
+	var status Status
+
+	steps := kb.Steps()
+	for i, step := range steps {
+		if r.isStopped() {
+			break
+		}
+
+		status = e.runStep(ctx, r, kb, step)
+		if !status.Failed() || r.isStopped() {
+			continue
+		}
+
+		msg := status.String()
+		if len(steps) > 1 {
+			msg = fmt.Sprintf("step %d: %s", i+1, msg)
+		}
+		log.Printf("Error: %s: %s", kb.Name, msg)
+		if !step.ContinueOnError {
+			break
+		}
+	}
+
+	r.mu.Lock()
+	status.PID = r.pid
+	status.TimedOut = r.timedOut
+	r.mu.Unlock()
+	status.Duration = time.Since(r.started)
+	return status
+}
+
+// runStep runs one step, or all members of a parallel group at once, and
+// waits for it to finish. A group reports its first failed member.
+func (e *Executor) runStep(ctx context.Context, r *run, kb *config.Keybinding, step config.Step) Status {
+	if len(step.Parallel) == 0 {
+		return e.runProcess(ctx, r, stepBinding(kb, step))
+	}
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		result Status
+	)
+	for _, member := range step.Parallel {
+		wg.Go(func() {
+			status := e.runProcess(ctx, r, stepBinding(kb, member))
+
+			mu.Lock()
+			if !result.Failed() {
+				result = status
+			}
+			mu.Unlock()
+		})
+	}
+	wg.Wait()
+	return result
+}
+
+// runProcess starts one process of a run and waits for it
+func (e *Executor) runProcess(ctx context.Context, r *run, kb *config.Keybinding) Status {
+	wait, err := e.spawn(ctx, r, kb)
+	if err != nil {
+		return startFailed(err)
+	}
+	return wait()
+}
+
+// stepBinding returns a copy of the keybinding that runs only the step, so
+// steps share the binding's environment, user and output
+func stepBinding(kb *config.Keybinding, step config.Step) *config.Keybinding {
+	resolved := *kb
+	resolved.Run = step.Run
+	resolved.File = step.File
+	resolved.Args = step.Args
+	resolved.Interpreter = step.Interpreter
+	resolved.Script = step.Script
+	resolved.Actions = nil
+	return &resolved
+}
+
+// spawn starts one process of a run. wait waits for it to exit, releases
+// what it used and returns how it ended.
+func (e *Executor) spawn(ctx context.Context, r *run, kb *config.Keybinding) (wait func() Status, err error) {
+	acct, err := lookupAccount(kb.User)
+	if err != nil {
+		return nil, err
+	}
+	kb = e.withImports(kb, acct)
+	if acct != nil {
+		kb = acct.apply(kb)
+	}
+
+	cmd, cleanup, err := e.command(ctx, kb, acct)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := setEnvironment(cmd, kb, acct); err != nil {
+		cleanup()
+		return nil, err
+	}
+
+	closeOutput, err := attachOutput(cmd, kb, acct)
+	if err != nil {
+		cleanup()
+		return nil, err
+	}
+
+	// Own process group so the whole action can be stopped at once,
+	// including the children a shell started
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	if acct != nil {
+		cmd.SysProcAttr.Credential = acct.credential()
+	}
+
+	// Started under the lock so a concurrent stop sees the process
+	r.mu.Lock()
+	if r.stopped {
+		r.mu.Unlock()
+		closeOutput()
+		cleanup()
+		return nil, errors.New("stopped")
+	}
+	started := time.Now()
+	err = cmd.Start()
+	if err == nil {
+		r.procs = append(r.procs, cmd)
+		if r.pid == 0 {
+			r.pid = cmd.Process.Pid
+			e.history.setPID(r.entry, r.pid)
+		}
+	}
+	r.mu.Unlock()
+
+	if err != nil {
+		closeOutput()
+		cleanup()
+		return nil, fmt.Errorf("start: %w", err)
+	}
+
+	return func() Status {
+		err := cmd.Wait()
+		status := exitStatus(cmd, err, started)
+
+		r.mu.Lock()
+		r.procs = slices.DeleteFunc(r.procs, func(c *exec.Cmd) bool { return c == cmd })
+		r.mu.Unlock()
+
+		closeOutput()
+		cleanup()
+		return status
+	}, nil
+}
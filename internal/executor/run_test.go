@@ -0,0 +1,115 @@
+package executor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/glowfi/ghkd/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun_Steps(t *testing.T) {
+	step := func(line string) config.Step {
+		return config.Step{Run: config.Command{Line: line}}
+	}
+	failing := func(line string) config.Step {
+		return config.Step{Run: config.Command{Line: line}, ContinueOnError: true}
+	}
+
+	tests := []struct {
+		name        string
+		steps       []config.Step
+		stopAfter   string   // line written before the run is killed
+		wantLines   []string // written by the steps, in order
+		unordered   int      // the first lines may come in any order
+		wantStatus  string
+		maxDuration time.Duration
+	}{
+		{
+			name:       "should run steps in order :POS",
+			steps:      []config.Step{step("echo a >> $OUT"), step("echo b >> $OUT"), step("echo c >> $OUT")},
+			wantLines:  []string{"a", "b", "c"},
+			wantStatus: "exit 0",
+		},
+		{
+			name:       "should end the pipeline at a failed step :NEG",
+			steps:      []config.Step{step("echo a >> $OUT"), step("exit 3"), step("echo c >> $OUT")},
+			wantLines:  []string{"a"},
+			wantStatus: "exit 3",
+		},
+		{
+			name:       "should go on after a failed step with continue_on_error :POS",
+			steps:      []config.Step{step("echo a >> $OUT"), failing("exit 3"), step("echo c >> $OUT")},
+			wantLines:  []string{"a", "c"},
+			wantStatus: "exit 0",
+		},
+		{
+			name: "should run a parallel group at once before the next step :POS",
+			steps: []config.Step{
+				{Parallel: []config.Step{step("sleep 0.3; echo p1 >> $OUT"), step("sleep 0.3; echo p2 >> $OUT")}},
+				step("echo after >> $OUT"),
+			},
+			wantLines:   []string{"p1", "p2", "after"},
+			unordered:   2,
+			wantStatus:  "exit 0",
+			maxDuration: 550 * time.Millisecond,
+		},
+		{
+			name: "should fail a parallel group with its failed member :NEG",
+			steps: []config.Step{
+				{Parallel: []config.Step{step("echo p1 >> $OUT"), step("exit 2")}},
+				step("echo after >> $OUT"),
+			},
+			wantLines:  []string{"p1"},
+			wantStatus: "exit 2",
+		},
+		{
+			name:       "should skip the remaining steps of a stopped pipeline :NEG",
+			steps:      []config.Step{step("echo a >> $OUT"), step("sleep 5"), step("echo c >> $OUT")},
+			stopAfter:  "a",
+			wantLines:  []string{"a"},
+			wantStatus: "killed by SIGTERM",
+		},
+	}
+
+	for _, tt := range tests {
+		out := filepath.Join(t.TempDir(), "out")
+		e := New()
+		kb := &config.Keybinding{
+			Name:    "Pipeline",
+			Actions: tt.steps,
+			Env:     map[string]string{"OUT": out},
+		}
+
+		require.NoError(t, e.Execute(context.Background(), kb, Trigger{}), tt.name)
+		if tt.stopAfter != "" {
+			require.Eventually(t, func() bool {
+				data, _ := os.ReadFile(out)
+				return string(data) == tt.stopAfter+"\n" && e.IsRunning("Pipeline")
+			}, 5*time.Second, 5*time.Millisecond, tt.name)
+			time.Sleep(50 * time.Millisecond) // let the next step start
+			e.Kill("Pipeline")
+		}
+		waitIdle(t, e)
+
+		data, err := os.ReadFile(out)
+		require.NoError(t, err, tt.name)
+		lines := strings.Fields(string(data))
+		slices.Sort(lines[:min(tt.unordered, len(lines))])
+		assert.Equal(t, tt.wantLines, lines, tt.name)
+
+		entries := e.History("Pipeline")
+		require.Len(t, entries, 1, tt.name)
+		assert.Equal(t, tt.wantStatus, entries[0].Status, tt.name)
+		if tt.maxDuration > 0 {
+			assert.Less(t, entries[0].DurationMs, tt.maxDuration.Milliseconds(), "expect members of a group to run side by side")
+		}
+		assert.NoError(t, e.Shutdown(), tt.name)
+	}
+}
@@ -0,0 +1,188 @@
+package executor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"syscall"
+
+	"github.com/glowfi/ghkd/internal/config"
+)
+
+// systemScriptDir holds the scripts of a daemon running as root. Root's
+// own runtime directory can't be entered by the users scripts run as.
+const systemScriptDir = "/run/ghkd/scripts"
+
+// scriptStore keeps inline scripts as files named by the hash of their
+// content. Each user the scripts run as has a private 0700 directory
+// below the store; the store itself only lets users reach their own.
+type scriptStore struct {
+	mu    sync.Mutex
+	dir   string          // ScriptDir
+	files map[string]bool // scripts written for the loaded config
+}
+
+// ScriptDir returns the directory inline scripts are written to:
+// /run/ghkd/scripts for a daemon running as root, otherwise
+// $XDG_RUNTIME_DIR/ghkd, or ghkd-<uid> in the temp directory
+func ScriptDir() string {
+	if os.Geteuid() == 0 {
+		return systemScriptDir
+	}
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "ghkd")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("ghkd-%d", os.Geteuid()))
+}
+
+// LoadScripts writes the inline scripts of the bindings and removes the
+// ones the previous config used (Thread-Safe)
+func (e *Executor) LoadScripts(bindings []config.Keybinding) {
+	e.scripts.mu.Lock()
+	defer e.scripts.mu.Unlock()
+
+	stale := e.scripts.files
+	e.scripts.files = make(map[string]bool)
+
+	for _, kb := range bindings {
+		acct, err := lookupAccount(kb.User)
+		if err != nil {
+			// Reported when the binding runs
+			continue
+		}
+
+		for _, script := range inlineScripts(kb.Steps()) {
+			path, err := e.scripts.write(script, acct)
+			if err != nil {
+				log.Printf("Warning: %s: %v", kb.Name, err)
+				continue
+			}
+			delete(stale, path)
+		}
+	}
+
+	for path := range stale {
+		os.Remove(path)
+	}
+}
+
+// inlineScripts returns the scripts of steps and their parallel members
+func inlineScripts(steps []config.Step) []string {
+	var scripts []string
+	for _, step := range steps {
+		if step.Script != "" {
+			scripts = append(scripts, step.Script)
+		}
+		scripts = append(scripts, inlineScripts(step.Parallel)...)
+	}
+	return scripts
+}
+
+// scriptPath returns the file of an inline script, writing it if it is
+// missing (Thread-Safe)
+func (e *Executor) scriptPath(script string, acct *account) (string, error) {
+	e.scripts.mu.Lock()
+	defer e.scripts.mu.Unlock()
+	return e.scripts.write(script, acct)
+}
+
+// removeScripts deletes every written script (Thread-Safe)
+func (e *Executor) removeScripts() {
+	e.scripts.mu.Lock()
+	defer e.scripts.mu.Unlock()
+
+	for path := range e.scripts.files {
+		os.Remove(path)
+		// Only succeeds once the user's directory is empty
+		os.Remove(filepath.Dir(path))
+	}
+	e.scripts.files = nil
+	os.Remove(e.scripts.dir)
+}
+
+// write stores a script in the directory of its user unless an identical
+// one is there already. Must be called with s.mu held.
+func (s *scriptStore) write(script string, acct *account) (string, error) {
+	uid, gid := os.Geteuid(), os.Getegid()
+	if acct != nil {
+		uid, gid = int(acct.uid), int(acct.gid)
+	}
+
+	// Other users only pass through to their own directory
+	base := s.dir
+	if err := os.MkdirAll(filepath.Dir(base), 0o755); err != nil {
+		return "", fmt.Errorf("script dir: %w", err)
+	}
+	if err := privateDir(base, 0o711, os.Geteuid(), os.Getegid()); err != nil {
+		return "", fmt.Errorf("script dir: %w", err)
+	}
+	dir := filepath.Join(base, strconv.Itoa(uid))
+	if err := privateDir(dir, 0o700, uid, gid); err != nil {
+		return "", fmt.Errorf("script dir: %w", err)
+	}
+
+	sum := sha256.Sum256([]byte(script))
+	path := filepath.Join(dir, hex.EncodeToString(sum[:16]))
+
+	if s.files == nil {
+		s.files = make(map[string]bool)
+	}
+	if _, err := os.Lstat(path); err == nil {
+		s.files[path] = true
+		return path, nil
+	}
+
+	// Written under a temporary name so a concurrent run never sees half
+	// a script
+	tmp, err := os.CreateTemp(dir, ".script-*")
+	if err != nil {
+		return "", fmt.Errorf("write script: %w", err)
+	}
+	_, err = tmp.WriteString(script)
+	err = errors.Join(err, tmp.Chmod(0o700), tmp.Chown(uid, gid), tmp.Close())
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("write script: %w", err)
+	}
+
+	s.files[path] = true
+	return path, nil
+}
+
+// privateDir creates a directory owned by uid with the given mode. An
+// existing one must be a real directory owned by uid, so nobody can plant
+// it in a shared temp directory beforehand.
+func privateDir(path string, perm os.FileMode, uid, gid int) error {
+	err := os.Mkdir(path, perm)
+	if err == nil {
+		if err := os.Chown(path, uid, gid); err != nil {
+			return err
+		}
+		return os.Chmod(path, perm)
+	}
+	if !errors.Is(err, os.ErrExist) {
+		return err
+	}
+
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !info.IsDir() || !ok || int(stat.Uid) != uid {
+		return fmt.Errorf("%s: not a directory owned by uid %d", path, uid)
+	}
+	if info.Mode().Perm() != perm {
+		return os.Chmod(path, perm)
+	}
+	return nil
+}
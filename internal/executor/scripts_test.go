@@ -0,0 +1,169 @@
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/glowfi/ghkd/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ownerOf returns the uid owning path
+func ownerOf(t *testing.T, path string) int {
+	t.Helper()
+	info, err := os.Lstat(path)
+	require.NoError(t, err)
+	return int(info.Sys().(*syscall.Stat_t).Uid)
+}
+
+func TestScripts_Write(t *testing.T) {
+	tests := []struct {
+		name    string
+		acct    *account
+		wantUID int
+	}{
+		{
+			name:    "should write scripts of the daemon's user :POS",
+			acct:    nil,
+			wantUID: os.Geteuid(),
+		},
+		{
+			name:    "should write scripts of another account to its own directory :POS",
+			acct:    &account{name: "nobody", uid: 65534, gid: 65534},
+			wantUID: 65534,
+		},
+	}
+
+	for _, tt := range tests {
+		if tt.acct != nil && os.Geteuid() != 0 {
+			continue
+		}
+
+		store := scriptStore{dir: filepath.Join(t.TempDir(), "ghkd")}
+		path, err := store.write("echo hi\n", tt.acct)
+		require.NoError(t, err, tt.name)
+
+		again, err := store.write("echo hi\n", tt.acct)
+		require.NoError(t, err, tt.name)
+		assert.Equal(t, path, again, "expect the same script to be written once")
+
+		content, err := os.ReadFile(path)
+		require.NoError(t, err, tt.name)
+		assert.Equal(t, "echo hi\n", string(content), tt.name)
+
+		info, err := os.Stat(path)
+		require.NoError(t, err, tt.name)
+		assert.Equal(t, os.FileMode(0o700), info.Mode().Perm(), tt.name)
+		assert.Equal(t, tt.wantUID, ownerOf(t, path), tt.name)
+		assert.Equal(t, tt.wantUID, ownerOf(t, filepath.Dir(path)), tt.name)
+
+		base, err := os.Stat(store.dir)
+		require.NoError(t, err, tt.name)
+		assert.Equal(t, os.FileMode(0o711), base.Mode().Perm(), "expect others to only pass through the store")
+	}
+}
+
+func TestScripts_PrivateDir(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(path string)
+		wantErr bool
+	}{
+		{
+			name:  "should create a missing directory :POS",
+			setup: func(path string) {},
+		},
+		{
+			name: "should fix the mode of an own directory :POS",
+			setup: func(path string) {
+				require.NoError(t, os.Mkdir(path, 0o755))
+			},
+		},
+		{
+			name: "should refuse a symlink :NEG",
+			setup: func(path string) {
+				require.NoError(t, os.Symlink(t.TempDir(), path))
+			},
+			wantErr: true,
+		},
+		{
+			name: "should refuse a file :NEG",
+			setup: func(path string) {
+				require.NoError(t, os.WriteFile(path, nil, 0o600))
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "dir")
+		tt.setup(path)
+
+		err := privateDir(path, 0o700, os.Geteuid(), os.Getegid())
+		if tt.wantErr {
+			assert.Error(t, err, tt.name)
+			continue
+		}
+		require.NoError(t, err, tt.name)
+
+		info, err := os.Lstat(path)
+		require.NoError(t, err, tt.name)
+		assert.True(t, info.IsDir(), tt.name)
+		assert.Equal(t, os.FileMode(0o700), info.Mode().Perm(), tt.name)
+	}
+}
+
+func TestScripts_LoadScripts(t *testing.T) {
+	script := func(name, body string) config.Keybinding {
+		return config.Keybinding{Name: name, Interpreter: "sh", Script: body}
+	}
+
+	tests := []struct {
+		name     string
+		before   []config.Keybinding
+		after    []config.Keybinding
+		wantKept []string // scripts still on disk after the reload
+		wantGone []string // scripts removed by the reload
+	}{
+		{
+			name:     "should remove scripts the new config dropped :POS",
+			before:   []config.Keybinding{script("A", "echo a\n"), script("B", "echo b\n")},
+			after:    []config.Keybinding{script("A", "echo a\n")},
+			wantKept: []string{"echo a\n"},
+			wantGone: []string{"echo b\n"},
+		},
+		{
+			name:     "should keep scripts another binding still uses :POS",
+			before:   []config.Keybinding{script("A", "echo a\n")},
+			after:    []config.Keybinding{script("C", "echo a\n")},
+			wantKept: []string{"echo a\n"},
+		},
+	}
+
+	for _, tt := range tests {
+		e := New()
+		e.scripts.dir = filepath.Join(t.TempDir(), "ghkd")
+
+		paths := map[string]string{}
+		e.LoadScripts(tt.before)
+		for _, kb := range tt.before {
+			path, err := e.scriptPath(kb.Script, nil)
+			require.NoError(t, err, tt.name)
+			paths[kb.Script] = path
+		}
+
+		e.LoadScripts(tt.after)
+		for _, body := range tt.wantKept {
+			assert.FileExists(t, paths[body], tt.name)
+		}
+		for _, body := range tt.wantGone {
+			assert.NoFileExists(t, paths[body], tt.name)
+		}
+
+		e.removeScripts()
+		assert.NoDirExists(t, e.scripts.dir, "expect the store to be removed on shutdown")
+	}
+}
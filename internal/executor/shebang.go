@@ -0,0 +1,49 @@
+package executor
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// maxShebang is how much of a file is read looking for a shebang line,
+// the limit of the kernel
+const maxShebang = 256
+
+// shebang returns the interpreter and its optional argument from the
+// first line of a script, or nothing if it has no shebang. Like the
+// kernel, everything after the interpreter is a single argument, and a
+// shebang line longer than maxShebang is an error rather than cut short.
+func shebang(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("read shebang: %w", err)
+	}
+	defer f.Close()
+
+	line, err := bufio.NewReader(io.LimitReader(f, maxShebang)).ReadBytes('\n')
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("read shebang: %w", err)
+	}
+
+	rest, found := bytes.CutPrefix(line, []byte("#!"))
+	if !found {
+		return nil, nil
+	}
+	if len(line) == maxShebang && line[len(line)-1] != '\n' {
+		return nil, fmt.Errorf("read shebang: first line longer than %d bytes", maxShebang)
+	}
+
+	fields := strings.TrimSpace(string(rest))
+	if fields == "" {
+		return nil, nil
+	}
+	end := strings.IndexAny(fields, " \t")
+	if end < 0 {
+		return []string{fields}, nil
+	}
+	return []string{fields[:end], strings.TrimSpace(fields[end:])}, nil
+}
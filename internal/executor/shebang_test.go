@@ -0,0 +1,124 @@
+package executor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/glowfi/ghkd/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShebang(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:    "should pass the rest of the line as one argument :POS",
+			content: "#!/usr/bin/env python3 -u\nprint('hi')\n",
+			want:    []string{"/usr/bin/env", "python3 -u"},
+		},
+		{
+			name:    "should read an interpreter without argument :POS",
+			content: "#!/bin/bash\necho hi\n",
+			want:    []string{"/bin/bash"},
+		},
+		{
+			name:    "should strip CRLF line endings :POS",
+			content: "#!/bin/sh -e\r\necho hi\r\n",
+			want:    []string{"/bin/sh", "-e"},
+		},
+		{
+			name:    "should allow spaces around the interpreter :POS",
+			content: "#! /bin/sh  -e \n",
+			want:    []string{"/bin/sh", "-e"},
+		},
+		{
+			name:    "should find nothing in a file without shebang :NEG",
+			content: "echo hi\n",
+			want:    nil,
+		},
+		{
+			name:    "should find nothing in an empty shebang :NEG",
+			content: "#!\n",
+			want:    nil,
+		},
+		{
+			name:    "should refuse a shebang line longer than the kernel reads :NEG",
+			content: "#!/" + strings.Repeat("a", maxShebang) + "\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "script")
+		require.NoError(t, os.WriteFile(path, []byte(tt.content), 0o644), tt.name)
+
+		got, err := shebang(path)
+		if tt.wantErr {
+			assert.Error(t, err, tt.name)
+			continue
+		}
+		require.NoError(t, err, tt.name)
+		assert.Equal(t, tt.want, got, tt.name)
+	}
+}
+
+func TestShebang_Command(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string, mode os.FileMode) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(content), mode))
+		return path
+	}
+	python := write("python.py", "#!/usr/bin/env python3 -u\n", 0o644)
+	plain := write("plain.sh", "echo hi\n", 0o644)
+	executable := write("run.sh", "#!/bin/sh\n", 0o755)
+	t.Setenv("GHKD_TEST_BIN", dir)
+
+	tests := []struct {
+		name     string
+		kb       config.Keybinding
+		wantArgs []string
+	}{
+		{
+			name:     "should run a file with its shebang interpreter :POS",
+			kb:       config.Keybinding{File: python, Args: []string{"a b"}},
+			wantArgs: []string{"/usr/bin/env", "python3 -u", python, "a b"},
+		},
+		{
+			name:     "should run a file without shebang with sh :POS",
+			kb:       config.Keybinding{File: plain, Args: []string{"x"}},
+			wantArgs: []string{"sh", plain, "x"},
+		},
+		{
+			name:     "should run an executable file directly :POS",
+			kb:       config.Keybinding{File: executable, Args: []string{"x"}},
+			wantArgs: []string{executable, "x"},
+		},
+		{
+			name:     "should run an argument list without a shell :POS",
+			kb:       config.Keybinding{Run: config.Command{Argv: []string{"$GHKD_TEST_BIN/run.sh", "a b", "$HOME"}}},
+			wantArgs: []string{executable, "a b", "$HOME"},
+		},
+		{
+			name:     "should run a command line through the shell :NEG",
+			kb:       config.Keybinding{Run: config.Command{Line: "echo $HOME"}, Shell: "bash"},
+			wantArgs: []string{"bash", "-c", "echo $HOME"},
+		},
+	}
+
+	for _, tt := range tests {
+		tt.kb.Name = "Command"
+		cmd, cleanup, err := New().command(context.Background(), &tt.kb, nil)
+		require.NoError(t, err, tt.name)
+		cleanup()
+		assert.Equal(t, tt.wantArgs, cmd.Args, tt.name)
+	}
+}
@@ -0,0 +1,105 @@
+package executor
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// Status is how a process, or a whole run, ended
+type Status struct {
+	PID      int           // First process of a run
+	ExitCode int           // -1 if killed by a signal or never started
+	Signal   string        // Signal that killed it: "SIGTERM"
+	Duration time.Duration // From start to exit
+	Err      error         // Why it could not start or be waited for
+	TimedOut bool          // Stopped by the binding's timeout
+}
+
+// Failed reports whether the process did not exit 0 or ran into its
+// timeout
+func (s Status) Failed() bool {
+	return s.Err != nil || s.ExitCode != 0 || s.TimedOut
+}
+
+func (s Status) String() string {
+	if s.TimedOut {
+		untimed := s
+		untimed.TimedOut = false
+		return fmt.Sprintf("timed out (%s)", untimed)
+	}
+
+	switch {
+	case s.Err != nil:
+		return fmt.Sprintf("error: %v", s.Err)
+	case s.Signal != "":
+		return "killed by " + s.Signal
+	default:
+		return fmt.Sprintf("exit %d", s.ExitCode)
+	}
+}
+
+// env returns the GHKD_EXIT_* variables describing the status for hooks
+func (s Status) env() map[string]string {
+	env := map[string]string{
+		"GHKD_EXIT_CODE": strconv.Itoa(s.ExitCode),
+		"GHKD_DURATION":  strconv.FormatInt(s.Duration.Milliseconds(), 10),
+		"GHKD_STATUS":    s.String(),
+	}
+	if s.Signal != "" {
+		env["GHKD_EXIT_SIGNAL"] = s.Signal
+	}
+	return env
+}
+
+// startFailed is the status of a process that never ran
+func startFailed(err error) Status {
+	return Status{ExitCode: -1, Err: err}
+}
+
+// exitStatus reads the status of a waited for process
+func exitStatus(cmd *exec.Cmd, waitErr error, started time.Time) Status {
+	s := Status{PID: cmd.Process.Pid, ExitCode: -1, Duration: time.Since(started)}
+
+	var exitErr *exec.ExitError
+	if waitErr != nil && !errors.As(waitErr, &exitErr) {
+		s.Err = waitErr
+	}
+
+	if cmd.ProcessState == nil {
+		return s
+	}
+	s.ExitCode = cmd.ProcessState.ExitCode()
+	if ws, ok := cmd.ProcessState.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		s.Signal = signalName(ws.Signal())
+	}
+	return s
+}
+
+// signalNames are the signals that usually end an action
+var signalNames = map[syscall.Signal]string{
+	syscall.SIGHUP:  "SIGHUP",
+	syscall.SIGINT:  "SIGINT",
+	syscall.SIGQUIT: "SIGQUIT",
+	syscall.SIGILL:  "SIGILL",
+	syscall.SIGABRT: "SIGABRT",
+	syscall.SIGBUS:  "SIGBUS",
+	syscall.SIGFPE:  "SIGFPE",
+	syscall.SIGKILL: "SIGKILL",
+	syscall.SIGUSR1: "SIGUSR1",
+	syscall.SIGSEGV: "SIGSEGV",
+	syscall.SIGUSR2: "SIGUSR2",
+	syscall.SIGPIPE: "SIGPIPE",
+	syscall.SIGALRM: "SIGALRM",
+	syscall.SIGTERM: "SIGTERM",
+}
+
+func signalName(sig syscall.Signal) string {
+	if name, found := signalNames[sig]; found {
+		return name
+	}
+	return fmt.Sprintf("signal %d", int(sig))
+}
@@ -0,0 +1,47 @@
+package executor
+
+import (
+	"maps"
+	"strconv"
+	"time"
+
+	"github.com/glowfi/ghkd/internal/config"
+)
+
+// Trigger describes the key event that started an action
+type Trigger struct {
+	Keys       string    // Keys held, in press order: "super+shift+b"
+	Device     string    // Name of the device the last key came from
+	DevicePath string    // Node path of the device the last key came from
+	Mode       string    // Active mode when the binding matched
+	Kind       string    // Event the binding fired on: config.OnPress, OnRelease or OnHold
+	Time       time.Time // Kernel timestamp of the event
+	Repeat     int       // Auto-repeats of the combo so far, 0 on press
+}
+
+// env returns the GHKD_* variables describing the trigger
+func (t Trigger) env(name string) map[string]string {
+	return map[string]string{
+		"GHKD_BINDING":      name,
+		"GHKD_KEYS":         t.Keys,
+		"GHKD_DEVICE_NAME":  t.Device,
+		"GHKD_DEVICE_PATH":  t.DevicePath,
+		"GHKD_MODE":         t.Mode,
+		"GHKD_TRIGGER":      t.Kind,
+		"GHKD_TIMESTAMP":    t.Time.Format(time.RFC3339Nano),
+		"GHKD_REPEAT_COUNT": strconv.Itoa(t.Repeat),
+	}
+}
+
+// withTrigger returns a copy of the keybinding with the trigger variables
+// over its own, so every step of the action sees why it ran
+func withTrigger(kb *config.Keybinding, trig Trigger) *config.Keybinding {
+	resolved := *kb
+	env := maps.Clone(kb.Env)
+	if env == nil {
+		env = map[string]string{}
+	}
+	maps.Copy(env, trig.env(kb.Name))
+	resolved.Env = env
+	return &resolved
+}
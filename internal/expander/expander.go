@@ -0,0 +1,186 @@
+package expander
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/glowfi/ghkd/internal/config"
+	"github.com/glowfi/ghkd/internal/hotkey"
+	"github.com/holoplot/go-evdev"
+)
+
+// DeviceName is the name of the virtual keyboard used to type expansions
+const DeviceName = hotkey.VirtualDevicePrefix + "virtual keyboard"
+
+// keyDelay gives the compositor time to process each synthesized key
+const keyDelay = 2 * time.Millisecond
+
+// Expander replaces typed abbreviations with their expansion text
+type Expander struct {
+	mu         sync.RWMutex
+	expansions []config.Expansion
+	keyboard   *evdev.InputDevice
+}
+
+// New creates a new expander
+func New(expansions []config.Expansion) *Expander {
+	return &Expander{
+		expansions: expansions,
+	}
+}
+
+// Open creates the virtual keyboard used to type expansions. It is a no-op
+// when the keyboard is already open.
+func (e *Expander) Open() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.keyboard != nil {
+		return nil
+	}
+
+	codes := []evdev.EvCode{
+		evdev.EvCode(hotkey.KEY_BACKSPACE),
+		evdev.EvCode(hotkey.KEY_LEFTSHIFT),
+	}
+	for _, code := range hotkey.LayoutKeys() {
+		codes = append(codes, evdev.EvCode(code))
+	}
+
+	keyboard, err := evdev.CreateDevice(
+		DeviceName,
+		evdev.InputID{BusType: evdev.BUS_VIRTUAL},
+		map[evdev.EvType][]evdev.EvCode{
+			evdev.EV_KEY: codes,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("create virtual keyboard: %w", err)
+	}
+
+	e.keyboard = keyboard
+	return nil
+}
+
+// Update replaces the current expansions with new ones (Thread-Safe)
+func (e *Expander) Update(expansions []config.Expansion) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.expansions = expansions
+}
+
+// Match finds the expansion whose trigger ends the typed text (Thread-Safe)
+func (e *Expander) Match(typed string) *config.Expansion {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	var match *config.Expansion
+	for i := range e.expansions {
+		exp := &e.expansions[i]
+		if !strings.HasSuffix(typed, exp.Trigger) {
+			continue
+		}
+		// Prefer the longest trigger so ":shrug:" wins over "rug:"
+		if match == nil || len(exp.Trigger) > len(match.Trigger) {
+			match = exp
+		}
+	}
+	return match
+}
+
+// Expand erases the typed trigger and types the expansion text
+func (e *Expander) Expand(exp *config.Expansion) error {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if e.keyboard == nil {
+		return fmt.Errorf("virtual keyboard not open")
+	}
+
+	keys, err := strokes(exp)
+	if err != nil {
+		return err
+	}
+	for _, stroke := range keys {
+		if err := e.tap(stroke); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// strokes returns the key strokes that erase the typed trigger, one
+// backspace per character, and then type the expansion text
+func strokes(exp *config.Expansion) ([]hotkey.KeyStroke, error) {
+	var keys []hotkey.KeyStroke
+	for range []rune(exp.Trigger) {
+		keys = append(keys, hotkey.KeyStroke{Code: hotkey.KEY_BACKSPACE})
+	}
+
+	for _, char := range exp.Text {
+		stroke, ok := hotkey.LookupKeyStroke(char)
+		if !ok {
+			return nil, fmt.Errorf("%q: %w", char, config.ErrUntypeableCharacter)
+		}
+		keys = append(keys, stroke)
+	}
+	return keys, nil
+}
+
+// tap presses and releases a key, holding shift around it when needed
+func (e *Expander) tap(stroke hotkey.KeyStroke) error {
+	if stroke.Shift {
+		if err := e.send(hotkey.KEY_LEFTSHIFT, hotkey.KEY_PRESSED); err != nil {
+			return err
+		}
+	}
+
+	if err := e.send(stroke.Code, hotkey.KEY_PRESSED); err != nil {
+		return err
+	}
+	if err := e.send(stroke.Code, hotkey.KEY_RELEASED); err != nil {
+		return err
+	}
+
+	if stroke.Shift {
+		if err := e.send(hotkey.KEY_LEFTSHIFT, hotkey.KEY_RELEASED); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// send writes a single key event followed by a sync report
+func (e *Expander) send(code uint16, value int32) error {
+	events := []evdev.InputEvent{
+		{Type: evdev.EV_KEY, Code: evdev.EvCode(code), Value: value},
+		{Type: evdev.EV_SYN, Code: evdev.SYN_REPORT},
+	}
+
+	for i := range events {
+		if err := e.keyboard.WriteOne(&events[i]); err != nil {
+			return fmt.Errorf("write key event: %w", err)
+		}
+	}
+
+	time.Sleep(keyDelay)
+	return nil
+}
+
+// Close destroys the virtual keyboard
+func (e *Expander) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.keyboard == nil {
+		return nil
+	}
+
+	err := errors.Join(evdev.DestroyDevice(e.keyboard), e.keyboard.Close())
+	e.keyboard = nil
+	return err
+}
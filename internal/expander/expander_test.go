@@ -0,0 +1,107 @@
+package expander
+
+import (
+	"testing"
+
+	"github.com/glowfi/ghkd/internal/config"
+	"github.com/glowfi/ghkd/internal/hotkey"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpander_Match(t *testing.T) {
+	expansions := []config.Expansion{
+		{Trigger: "rug:", Text: "carpet"},
+		{Trigger: ":shrug:", Text: `¯\_(ツ)_/¯`},
+		{Trigger: ";sig", Text: "Best regards"},
+	}
+
+	tests := []struct {
+		name        string
+		typed       string
+		wantTrigger string // empty for no match
+	}{
+		{
+			name:        "should match a trigger at the end of the typed text :POS",
+			typed:       "thanks ;sig",
+			wantTrigger: ";sig",
+		},
+		{
+			name:        "should prefer the longest trigger :POS",
+			typed:       "ok :shrug:",
+			wantTrigger: ":shrug:",
+		},
+		{
+			name:        "should match the shorter trigger alone :POS",
+			typed:       "a rug:",
+			wantTrigger: "rug:",
+		},
+		{
+			name:        "should not match a partial trigger :NEG",
+			typed:       "thanks ;si",
+			wantTrigger: "",
+		},
+		{
+			name:        "should not match a trigger followed by more text :NEG",
+			typed:       ";sig ",
+			wantTrigger: "",
+		},
+	}
+
+	for _, tt := range tests {
+		match := New(expansions).Match(tt.typed)
+		if tt.wantTrigger == "" {
+			assert.Nil(t, match, tt.name)
+			continue
+		}
+		if assert.NotNil(t, match, tt.name) {
+			assert.Equal(t, tt.wantTrigger, match.Trigger, tt.name)
+		}
+	}
+}
+
+func TestExpander_Strokes(t *testing.T) {
+	backspace := hotkey.KeyStroke{Code: hotkey.KEY_BACKSPACE}
+
+	tests := []struct {
+		name    string
+		exp     config.Expansion
+		want    []hotkey.KeyStroke
+		wantErr bool
+	}{
+		{
+			name: "should erase the trigger and type the text :POS",
+			exp:  config.Expansion{Trigger: ";b", Text: "Hi!"},
+			want: []hotkey.KeyStroke{
+				backspace, backspace,
+				{Code: hotkey.KEY_H, Shift: true},
+				{Code: hotkey.KEY_I},
+				{Code: hotkey.KEY_1, Shift: true},
+			},
+		},
+		{
+			name: "should erase one backspace per character of the trigger :POS",
+			exp:  config.Expansion{Trigger: ";é", Text: "a"},
+			want: []hotkey.KeyStroke{backspace, backspace, {Code: hotkey.KEY_A}},
+		},
+		{
+			name: "should type spaces and new lines :POS",
+			exp:  config.Expansion{Trigger: ";n", Text: " \n"},
+			want: []hotkey.KeyStroke{backspace, backspace, {Code: hotkey.KEY_SPACE}, {Code: hotkey.KEY_ENTER}},
+		},
+		{
+			name:    "should refuse characters the layout can't type :NEG",
+			exp:     config.Expansion{Trigger: ";s", Text: "ツ"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := strokes(&tt.exp)
+		if tt.wantErr {
+			assert.ErrorIs(t, err, config.ErrUntypeableCharacter, tt.name)
+			continue
+		}
+		assert.NoError(t, err, tt.name)
+		assert.Equal(t, tt.want, got, tt.name)
+	}
+}
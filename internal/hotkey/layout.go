@@ -0,0 +1,106 @@
+package hotkey
+
+// VirtualDevicePrefix starts the name of every virtual device ghkd creates,
+// so they are never listened to
+const VirtualDevicePrefix = "ghkd "
+
+// KeyStroke is a single key press needed to produce a character
+type KeyStroke struct {
+	Code  uint16
+	Shift bool
+}
+
+// usLayout maps characters to key strokes on a US QWERTY layout
+var usLayout = map[rune]KeyStroke{
+	// Letters
+	'a': {KEY_A, false}, 'A': {KEY_A, true},
+	'b': {KEY_B, false}, 'B': {KEY_B, true},
+	'c': {KEY_C, false}, 'C': {KEY_C, true},
+	'd': {KEY_D, false}, 'D': {KEY_D, true},
+	'e': {KEY_E, false}, 'E': {KEY_E, true},
+	'f': {KEY_F, false}, 'F': {KEY_F, true},
+	'g': {KEY_G, false}, 'G': {KEY_G, true},
+	'h': {KEY_H, false}, 'H': {KEY_H, true},
+	'i': {KEY_I, false}, 'I': {KEY_I, true},
+	'j': {KEY_J, false}, 'J': {KEY_J, true},
+	'k': {KEY_K, false}, 'K': {KEY_K, true},
+	'l': {KEY_L, false}, 'L': {KEY_L, true},
+	'm': {KEY_M, false}, 'M': {KEY_M, true},
+	'n': {KEY_N, false}, 'N': {KEY_N, true},
+	'o': {KEY_O, false}, 'O': {KEY_O, true},
+	'p': {KEY_P, false}, 'P': {KEY_P, true},
+	'q': {KEY_Q, false}, 'Q': {KEY_Q, true},
+	'r': {KEY_R, false}, 'R': {KEY_R, true},
+	's': {KEY_S, false}, 'S': {KEY_S, true},
+	't': {KEY_T, false}, 'T': {KEY_T, true},
+	'u': {KEY_U, false}, 'U': {KEY_U, true},
+	'v': {KEY_V, false}, 'V': {KEY_V, true},
+	'w': {KEY_W, false}, 'W': {KEY_W, true},
+	'x': {KEY_X, false}, 'X': {KEY_X, true},
+	'y': {KEY_Y, false}, 'Y': {KEY_Y, true},
+	'z': {KEY_Z, false}, 'Z': {KEY_Z, true},
+
+	// Number row
+	'1': {KEY_1, false}, '!': {KEY_1, true},
+	'2': {KEY_2, false}, '@': {KEY_2, true},
+	'3': {KEY_3, false}, '#': {KEY_3, true},
+	'4': {KEY_4, false}, '$': {KEY_4, true},
+	'5': {KEY_5, false}, '%': {KEY_5, true},
+	'6': {KEY_6, false}, '^': {KEY_6, true},
+	'7': {KEY_7, false}, '&': {KEY_7, true},
+	'8': {KEY_8, false}, '*': {KEY_8, true},
+	'9': {KEY_9, false}, '(': {KEY_9, true},
+	'0': {KEY_0, false}, ')': {KEY_0, true},
+
+	// Punctuation
+	'-': {KEY_MINUS, false}, '_': {KEY_MINUS, true},
+	'=': {KEY_EQUAL, false}, '+': {KEY_EQUAL, true},
+	'[': {KEY_LEFTBRACE, false}, '{': {KEY_LEFTBRACE, true},
+	']': {KEY_RIGHTBRACE, false}, '}': {KEY_RIGHTBRACE, true},
+	';': {KEY_SEMICOLON, false}, ':': {KEY_SEMICOLON, true},
+	'\'': {KEY_APOSTROPHE, false}, '"': {KEY_APOSTROPHE, true},
+	'`': {KEY_GRAVE, false}, '~': {KEY_GRAVE, true},
+	'\\': {KEY_BACKSLASH, false}, '|': {KEY_BACKSLASH, true},
+	',': {KEY_COMMA, false}, '<': {KEY_COMMA, true},
+	'.': {KEY_DOT, false}, '>': {KEY_DOT, true},
+	'/': {KEY_SLASH, false}, '?': {KEY_SLASH, true},
+
+	// Whitespace
+	' ':  {KEY_SPACE, false},
+	'\t': {KEY_TAB, false},
+	'\n': {KEY_ENTER, false},
+}
+
+// usLayoutReverse maps key strokes back to the characters they produce
+var usLayoutReverse = func() map[KeyStroke]rune {
+	reverse := make(map[KeyStroke]rune, len(usLayout))
+	for char, stroke := range usLayout {
+		reverse[stroke] = char
+	}
+	return reverse
+}()
+
+// LookupKeyStroke returns the key stroke that types the given character
+func LookupKeyStroke(char rune) (KeyStroke, bool) {
+	stroke, ok := usLayout[char]
+	return stroke, ok
+}
+
+// LookupChar returns the character typed by a key, taking shift into account
+func LookupChar(code uint16, shift bool) (rune, bool) {
+	char, ok := usLayoutReverse[KeyStroke{Code: code, Shift: shift}]
+	return char, ok
+}
+
+// LayoutKeys returns every key code used by the layout table
+func LayoutKeys() []uint16 {
+	seen := map[uint16]bool{}
+	var codes []uint16
+	for _, stroke := range usLayout {
+		if !seen[stroke.Code] {
+			seen[stroke.Code] = true
+			codes = append(codes, stroke.Code)
+		}
+	}
+	return codes
+}
@@ -0,0 +1,8 @@
+package hotkey
+
+// Snapshot is the pressed key state handed to the matcher
+type Snapshot struct {
+	Keys       []uint16 // Keys held together, in press order
+	Device     string   // Name of the device the last key came from
+	DevicePath string   // Node path of the device the last key came from
+}
@@ -0,0 +1,240 @@
+// Package ipc is the control socket between the ghkd daemon and its CLI.
+// Each connection carries one JSON request and one JSON response.
+package ipc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// callTimeout bounds a whole request and response exchange
+const callTimeout = 5 * time.Second
+
+// SystemSocketDir holds the socket of a daemon running as root
+const SystemSocketDir = "/run/ghkd"
+
+// socketName is the file name of the control socket
+const socketName = "ghkd.sock"
+
+// Commands understood by the daemon
+const (
+	CommandSetEnv  = "setenv"
+	CommandHistory = "history"
+)
+
+// Request is sent by the CLI to the daemon
+type Request struct {
+	Command string            `json:"command"`
+	Env     map[string]string `json:"env,omitempty"`     // setenv: variables to import
+	Binding string            `json:"binding,omitempty"` // history: only runs of this binding
+}
+
+// Response is the daemon's answer to a request
+type Response struct {
+	Error string          `json:"error,omitempty"`
+	Data  json.RawMessage `json:"data,omitempty"` // Command specific result
+}
+
+// Handler answers requests. uid is the sender's, from the peer
+// credentials of the connection.
+type Handler func(uid uint32, req Request) Response
+
+// Server accepts requests on a unix socket. Only root, the daemon's own
+// user and the allowed users may connect.
+type Server struct {
+	listener *net.UnixListener
+	handler  Handler
+	mu       sync.RWMutex
+	allowed  []uint32
+	wg       sync.WaitGroup
+}
+
+// SocketPaths returns the control sockets the CLI tries, in order: the
+// user's own daemon in $XDG_RUNTIME_DIR, then a daemon running as root in
+// SystemSocketDir. A daemon listens on the first.
+func SocketPaths() []string {
+	system := filepath.Join(SystemSocketDir, socketName)
+	if os.Geteuid() == 0 {
+		return []string{system}
+	}
+
+	runtimeDir := os.Getenv("XDG_RUNTIME_DIR")
+	if runtimeDir == "" {
+		runtimeDir = fmt.Sprintf("/run/user/%d", os.Geteuid())
+	}
+	return []string{filepath.Join(runtimeDir, socketName), system}
+}
+
+// Listen creates the socket at path, replacing a stale one, and serves
+// requests until Close. The directory of path is created if missing and
+// must belong to the daemon's user, writable by nobody else.
+func Listen(path string, handler Handler) (*Server, error) {
+	if err := socketDir(filepath.Dir(path)); err != nil {
+		return nil, err
+	}
+
+	// A socket left behind by a daemon that died
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	// Peers are checked by uid, so other users must be able to connect. The
+	// mode comes from the umask as the socket is bound, so it never has to
+	// be changed by path.
+	umask := syscall.Umask(0o111)
+	listener, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	syscall.Umask(umask)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{listener: listener, handler: handler}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// Allow replaces the users, besides root and the daemon's user, that may
+// send requests (Thread-Safe)
+func (s *Server) Allow(uids []uint32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.allowed = uids
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.AcceptUnix()
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+		if err != nil {
+			log.Printf("Warning: ipc accept: %v", err)
+			continue
+		}
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handle(conn)
+		}()
+	}
+}
+
+func (s *Server) handle(conn *net.UnixConn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(callTimeout))
+
+	var resp Response
+	uid, err := peerUID(conn)
+	switch {
+	case err != nil:
+		resp.Error = fmt.Sprintf("peer credentials: %v", err)
+	case !s.permitted(uid):
+		resp.Error = fmt.Sprintf("uid %d may not control this daemon", uid)
+	default:
+		var req Request
+		if err := json.NewDecoder(conn).Decode(&req); err != nil {
+			resp.Error = fmt.Sprintf("decode request: %v", err)
+			break
+		}
+		resp = s.handler(uid, req)
+	}
+
+	if err := json.NewEncoder(conn).Encode(resp); err != nil {
+		log.Printf("Warning: ipc reply: %v", err)
+	}
+}
+
+func (s *Server) permitted(uid uint32) bool {
+	if uid == 0 || int(uid) == os.Geteuid() {
+		return true
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return slices.Contains(s.allowed, uid)
+}
+
+// Close stops accepting requests, waits for the ones in progress and
+// removes the socket
+func (s *Server) Close() error {
+	err := s.listener.Close()
+	s.wg.Wait()
+	return err
+}
+
+// socketDir creates the directory of the socket, or checks an existing
+// one: a real directory owned by the daemon's user that nobody else may
+// write to, so the socket can't be replaced
+func socketDir(dir string) error {
+	if err := os.Mkdir(dir, 0o755); err != nil && !errors.Is(err, os.ErrExist) {
+		return err
+	}
+
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !info.IsDir() || !ok || int(stat.Uid) != os.Geteuid() || info.Mode().Perm()&0o022 != 0 {
+		return fmt.Errorf("%s: not a private directory of uid %d", dir, os.Geteuid())
+	}
+	return nil
+}
+
+// peerUID returns the uid of the process on the other end of conn
+func peerUID(conn *net.UnixConn) (uint32, error) {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+
+	var cred *syscall.Ucred
+	var credErr error
+	err = raw.Control(func(fd uintptr) {
+		cred, credErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	})
+	if err != nil {
+		return 0, err
+	}
+	if credErr != nil {
+		return 0, credErr
+	}
+	return cred.Uid, nil
+}
+
+// Call sends a request to the daemon listening at path. A response that
+// carries an error is returned as one.
+func Call(path string, req Request) (Response, error) {
+	conn, err := net.DialTimeout("unix", path, callTimeout)
+	if err != nil {
+		return Response{}, fmt.Errorf("daemon not reachable at %s: %w", path, err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(callTimeout))
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return Response{}, err
+	}
+
+	var resp Response
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return Response{}, fmt.Errorf("read response: %w", err)
+	}
+	if resp.Error != "" {
+		return resp, errors.New(resp.Error)
+	}
+	return resp, nil
+}
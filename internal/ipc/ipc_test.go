@@ -0,0 +1,53 @@
+package ipc
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIPC_Listen(t *testing.T) {
+	tests := []struct {
+		name    string
+		dirMode os.FileMode
+		wantErr bool
+	}{
+		{
+			name:    "should serve requests from a private directory :POS",
+			dirMode: 0o700,
+		},
+		{
+			name:    "should refuse a directory others may write to :NEG",
+			dirMode: 0o777,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		dir := filepath.Join(t.TempDir(), "ghkd")
+		require.NoError(t, os.Mkdir(dir, tt.dirMode))
+		require.NoError(t, os.Chmod(dir, tt.dirMode))
+		path := filepath.Join(dir, socketName)
+
+		srv, err := Listen(path, func(uid uint32, req Request) Response {
+			return Response{Data: []byte(`"` + req.Command + `"`)}
+		})
+		if tt.wantErr {
+			assert.Error(t, err, tt.name)
+			continue
+		}
+		require.NoError(t, err, tt.name)
+
+		info, err := os.Stat(path)
+		require.NoError(t, err, tt.name)
+		assert.Equal(t, os.FileMode(0o666), info.Mode().Perm(), tt.name)
+
+		resp, err := Call(path, Request{Command: CommandHistory})
+		assert.NoError(t, err, tt.name)
+		assert.JSONEq(t, `"history"`, string(resp.Data), tt.name)
+		assert.NoError(t, srv.Close(), tt.name)
+	}
+}
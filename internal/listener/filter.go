@@ -0,0 +1,202 @@
+package listener
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/glowfi/ghkd/internal/config"
+	"github.com/glowfi/ghkd/internal/hotkey"
+	"github.com/holoplot/go-evdev"
+)
+
+// DeviceInfo describes an input device node
+type DeviceInfo struct {
+	Path     string
+	Name     string
+	Phys     string
+	ID       evdev.InputID
+	ByID     []string       // Names of /dev/input/by-id symlinks to this node
+	Types    []evdev.EvType // Supported event types
+	Keys     int            // Number of supported key codes
+	Keyboard bool           // Accepted by the keyboard heuristic
+	Err      error          // Set when the node could not be probed
+}
+
+// ListDevices probes every event node in inputDir, including the ones that
+// could not be opened
+func ListDevices(inputDir string) ([]DeviceInfo, error) {
+	matches, err := filepath.Glob(filepath.Join(inputDir, "event*"))
+	if err != nil {
+		return nil, err
+	}
+
+	// Sort event2 before event10
+	slices.SortFunc(matches, func(a, b string) int {
+		if len(a) != len(b) {
+			return len(a) - len(b)
+		}
+		return strings.Compare(a, b)
+	})
+
+	infos := make([]DeviceInfo, 0, len(matches))
+	for _, path := range matches {
+		info, err := probeDevice(path)
+		if err != nil {
+			info = DeviceInfo{Path: path, Err: err}
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// probeDevice reads the metadata of a device node
+func probeDevice(path string) (DeviceInfo, error) {
+	dev, err := evdev.OpenWithFlags(path, os.O_RDONLY)
+	if err != nil {
+		return DeviceInfo{}, err
+	}
+	defer dev.Close()
+
+	info := DeviceInfo{Path: path}
+
+	if info.Name, err = dev.Name(); err != nil {
+		return DeviceInfo{}, err
+	}
+	if info.ID, err = dev.InputID(); err != nil {
+		return DeviceInfo{}, err
+	}
+	// Not every device reports a physical location
+	info.Phys, _ = dev.PhysicalLocation()
+
+	info.ByID = byIDLinks(path)
+	info.Types = dev.CapableTypes()
+	info.Keys = len(dev.CapableEvents(evdev.EV_KEY))
+	info.Keyboard = isKeyboard(dev, info.Name)
+
+	return info, nil
+}
+
+// byIDLinks returns the names of by-id symlinks that point at path
+func byIDLinks(path string) []string {
+	dir := filepath.Join(filepath.Dir(path), "by-id")
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	var links []string
+	for _, entry := range entries {
+		target, err := filepath.EvalSymlinks(filepath.Join(dir, entry.Name()))
+		if err == nil && target == path {
+			links = append(links, entry.Name())
+		}
+	}
+	return links
+}
+
+// deviceRule is a config.DeviceRule with its patterns compiled
+type deviceRule struct {
+	config.DeviceRule
+	name *regexp.Regexp
+	phys *regexp.Regexp
+}
+
+func (r deviceRule) matches(info DeviceInfo) bool {
+	if r.name != nil && !r.name.MatchString(info.Name) {
+		return false
+	}
+	if r.phys != nil && !r.phys.MatchString(info.Phys) {
+		return false
+	}
+	if r.Vendor != nil && uint16(*r.Vendor) != info.ID.Vendor {
+		return false
+	}
+	if r.Product != nil && uint16(*r.Product) != info.ID.Product {
+		return false
+	}
+	if r.Bus != "" && config.BusTypes[strings.ToLower(r.Bus)] != info.ID.BusType {
+		return false
+	}
+	if r.ID != "" && !slices.Contains(info.ByID, r.ID) {
+		return false
+	}
+	if r.Path != "" && !samePath(r.Path, info.Path) {
+		return false
+	}
+	return true
+}
+
+// Filter decides which devices are listened to
+type Filter struct {
+	include []deviceRule
+	exclude []deviceRule
+}
+
+// NewFilter compiles the device rules of a config. Patterns are validated
+// when the config is loaded.
+func NewFilter(devices config.Devices) *Filter {
+	return &Filter{
+		include: compileRules(devices.Include),
+		exclude: compileRules(devices.Exclude),
+	}
+}
+
+func compileRules(rules []config.DeviceRule) []deviceRule {
+	compiled := make([]deviceRule, 0, len(rules))
+	for _, rule := range rules {
+		r := deviceRule{DeviceRule: rule}
+		if rule.Name != "" {
+			r.name = regexp.MustCompile(rule.Name)
+		}
+		if rule.Phys != "" {
+			r.phys = regexp.MustCompile(rule.Phys)
+		}
+		compiled = append(compiled, r)
+	}
+	return compiled
+}
+
+// Accept reports whether a device should be listened to. In order:
+// ghkd's own virtual devices are never read, an include rule with an
+// explicit path always wins, then exclude rules, then other include rules,
+// then the keyboard heuristic.
+func (f *Filter) Accept(info DeviceInfo) bool {
+	// Reading the expansion keyboard back would expand its own output
+	if strings.HasPrefix(info.Name, hotkey.VirtualDevicePrefix) {
+		return false
+	}
+
+	for _, rule := range f.include {
+		if rule.Path != "" && rule.matches(info) {
+			return true
+		}
+	}
+
+	for _, rule := range f.exclude {
+		if rule.matches(info) {
+			return false
+		}
+	}
+
+	for _, rule := range f.include {
+		if rule.matches(info) {
+			return true
+		}
+	}
+
+	return info.Keyboard
+}
+
+// samePath compares two paths after resolving symlinks
+func samePath(a, b string) bool {
+	if resolved, err := filepath.EvalSymlinks(a); err == nil {
+		a = resolved
+	}
+	if resolved, err := filepath.EvalSymlinks(b); err == nil {
+		b = resolved
+	}
+	return a == b
+}
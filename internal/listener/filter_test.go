@@ -0,0 +1,55 @@
+package listener
+
+import (
+	"testing"
+
+	"github.com/glowfi/ghkd/internal/config"
+	"github.com/glowfi/ghkd/internal/hotkey"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilter_Accept(t *testing.T) {
+	tests := []struct {
+		name    string
+		devices config.Devices
+		info    DeviceInfo
+		want    bool
+	}{
+		{
+			name: "should accept device matching include rule :POS",
+			devices: config.Devices{
+				Include: []config.DeviceRule{{Name: "(?i)keyboard"}},
+			},
+			info: DeviceInfo{Path: "/dev/input/event3", Name: "USB Keyboard"},
+			want: true,
+		},
+		{
+			name: "should reject own virtual keyboard despite include rule :NEG",
+			devices: config.Devices{
+				Include: []config.DeviceRule{{Name: "(?i)keyboard"}},
+			},
+			info: DeviceInfo{Path: "/dev/input/event9", Name: hotkey.VirtualDevicePrefix + "virtual keyboard", Keyboard: true},
+			want: false,
+		},
+		{
+			name: "should reject own virtual keyboard despite include path :NEG",
+			devices: config.Devices{
+				Include: []config.DeviceRule{{Path: "/dev/input/event9"}},
+			},
+			info: DeviceInfo{Path: "/dev/input/event9", Name: hotkey.VirtualDevicePrefix + "virtual keyboard"},
+			want: false,
+		},
+		{
+			name: "should reject device matching exclude rule :NEG",
+			devices: config.Devices{
+				Exclude: []config.DeviceRule{{Name: "YubiKey"}},
+			},
+			info: DeviceInfo{Path: "/dev/input/event5", Name: "Yubico YubiKey OTP", Keyboard: true},
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, NewFilter(tt.devices).Accept(tt.info), tt.name)
+	}
+}
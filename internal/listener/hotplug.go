@@ -0,0 +1,112 @@
+package listener
+
+import (
+	"bytes"
+	"log"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// watchMask covers device nodes appearing, disappearing and becoming
+// readable once udev has applied their permissions
+const watchMask = syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_ATTRIB
+
+// watcher reports event* nodes added to or removed from the input directory
+type watcher struct {
+	fd int
+}
+
+func newWatcher(dir string) (*watcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_NONBLOCK | syscall.IN_CLOEXEC)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := syscall.InotifyAddWatch(fd, dir, watchMask); err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+
+	return &watcher{fd: fd}, nil
+}
+
+// deviceChange is a single add or remove of a device node
+type deviceChange struct {
+	name    string
+	removed bool
+}
+
+// read drains pending notifications and returns the changed event* nodes
+func (w *watcher) read() ([]deviceChange, error) {
+	var changes []deviceChange
+	buf := make([]byte, 4096)
+
+	for {
+		n, err := syscall.Read(w.fd, buf)
+		if err == syscall.EAGAIN {
+			return changes, nil
+		}
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil {
+			return changes, err
+		}
+
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameBytes := buf[offset+syscall.SizeofInotifyEvent : offset+syscall.SizeofInotifyEvent+int(event.Len)]
+			offset += syscall.SizeofInotifyEvent + int(event.Len)
+
+			name := string(bytes.TrimRight(nameBytes, "\x00"))
+			if !strings.HasPrefix(name, "event") {
+				continue
+			}
+
+			changes = append(changes, deviceChange{
+				name:    name,
+				removed: event.Mask&syscall.IN_DELETE != 0,
+			})
+		}
+	}
+}
+
+func (w *watcher) Close() error {
+	return syscall.Close(w.fd)
+}
+
+// handleDeviceChanges opens keyboards as they are plugged in and drops removed ones
+func (l *Listener) handleDeviceChanges() {
+	changes, err := l.watcher.read()
+	if err != nil {
+		log.Printf("Warning: device watcher: %v", err)
+	}
+
+	for _, change := range changes {
+		path := filepath.Join(l.inputDir, change.name)
+		if change.removed {
+			l.removeDevice(path)
+			continue
+		}
+		l.tryAddDevice(path)
+	}
+}
+
+// tryAddDevice opens a new node if it is a keyboard. The node may be created
+// before udev grants access to it, so IN_ATTRIB triggers another attempt.
+func (l *Listener) tryAddDevice(path string) {
+	if l.hasDevice(path) {
+		return
+	}
+
+	info, err := l.probe(path)
+	if err != nil || !l.currentFilter().Accept(info) {
+		return
+	}
+
+	if err := l.addDevice(info); err != nil {
+		log.Printf("Warning: could not open %s: %v", path, err)
+	}
+}
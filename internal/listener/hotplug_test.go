@@ -0,0 +1,98 @@
+package listener
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/glowfi/ghkd/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHotplug_DeviceChanges(t *testing.T) {
+	// Fake device nodes are FIFOs, they can be opened and polled like one
+	infos := map[string]DeviceInfo{
+		"event1": {Name: "USB Keyboard", Keyboard: true},
+		"event2": {Name: "USB Mouse"},
+		"event3": {Name: "Macro Pad", Keyboard: true},
+		"mouse0": {Name: "USB Keyboard", Keyboard: true},
+	}
+
+	tests := []struct {
+		name       string
+		plugged    []string // nodes created, then handled
+		unplugged  []string // nodes removed after that, then handled
+		wantOpen   []string
+		wantProbed []string
+	}{
+		{
+			name:       "should open a plugged in keyboard :POS",
+			plugged:    []string{"event1"},
+			wantOpen:   []string{"event1"},
+			wantProbed: []string{"event1"},
+		},
+		{
+			name:       "should skip devices that are no keyboard :NEG",
+			plugged:    []string{"event2"},
+			wantProbed: []string{"event2"},
+		},
+		{
+			name:       "should skip devices the filter excludes :NEG",
+			plugged:    []string{"event3"},
+			wantProbed: []string{"event3"},
+		},
+		{
+			name:    "should ignore nodes other than event* :NEG",
+			plugged: []string{"mouse0"},
+		},
+		{
+			name:       "should drop an unplugged keyboard :POS",
+			plugged:    []string{"event1"},
+			unplugged:  []string{"event1"},
+			wantProbed: []string{"event1"},
+		},
+	}
+
+	for _, tt := range tests {
+		dir := t.TempDir()
+		l := NewListener(dir, config.Devices{Exclude: []config.DeviceRule{{Name: "^Macro"}}})
+
+		var probed []string
+		l.probe = func(path string) (DeviceInfo, error) {
+			info := infos[filepath.Base(path)]
+			info.Path = path
+			probed = append(probed, filepath.Base(path))
+			return info, nil
+		}
+
+		p, err := newPoller()
+		require.NoError(t, err, tt.name)
+		l.poller = p
+		w, err := newWatcher(dir)
+		require.NoError(t, err, tt.name)
+		l.watcher = w
+
+		for _, name := range tt.plugged {
+			require.NoError(t, syscall.Mkfifo(filepath.Join(dir, name), 0o600), tt.name)
+		}
+		l.handleDeviceChanges()
+		for _, name := range tt.unplugged {
+			require.NoError(t, os.Remove(filepath.Join(dir, name)), tt.name)
+		}
+		l.handleDeviceChanges()
+
+		var open []string
+		for _, d := range l.openDevices() {
+			open = append(open, filepath.Base(d.path))
+			l.closeDevice(d)
+		}
+		assert.Equal(t, tt.wantOpen, open, tt.name)
+		assert.Equal(t, tt.wantProbed, probed, tt.name)
+		assert.Empty(t, l.state.merged(), tt.name)
+
+		w.Close()
+		p.close()
+	}
+}
@@ -0,0 +1,46 @@
+package listener
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+const (
+	keyMax = 0x2ff // KEY_MAX from linux/input-event-codes.h
+
+	iocRead     = 2
+	iocNRShift  = 0
+	iocTypShift = 8
+	iocSzShift  = 16
+	iocDirShift = 30
+)
+
+// eviocgkey returns the EVIOCGKEY request for a buffer of size bytes
+func eviocgkey(size uintptr) uintptr {
+	return iocRead<<iocDirShift | size<<iocSzShift | 'E'<<iocTypShift | 0x18<<iocNRShift
+}
+
+// kernelKeys asks the kernel which keys are currently held down on a device
+func kernelKeys(fd int) ([]uint16, error) {
+	var bits [(keyMax + 7) / 8]byte
+
+	_, _, errno := syscall.Syscall(
+		syscall.SYS_IOCTL,
+		uintptr(fd),
+		eviocgkey(uintptr(len(bits))),
+		uintptr(unsafe.Pointer(&bits[0])),
+	)
+	if errno != 0 {
+		return nil, errno
+	}
+
+	var down []uint16
+	for i, b := range bits {
+		for bit := range 8 {
+			if b&(1<<bit) != 0 {
+				down = append(down, uint16(i*8+bit))
+			}
+		}
+	}
+	return down, nil
+}
@@ -1,143 +1,424 @@
 package listener
 
 import (
+	"bytes"
 	"context"
+	"encoding/binary"
 	"fmt"
-	"path/filepath"
-	"slices"
+	"log"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 
+	"github.com/glowfi/ghkd/internal/config"
 	"github.com/glowfi/ghkd/internal/hotkey"
 	"github.com/holoplot/go-evdev"
 )
 
+// readBufferEvents is the number of input events read per syscall
+const readBufferEvents = 64
+
 type Listener struct {
-	pressed  []uint16
-	devices  []*evdev.InputDevice
-	eventsC  chan uint16
-	inputDir string
-	wg       sync.WaitGroup
-	mu       sync.RWMutex
+	state     *keyState
+	devices   map[int]*device // keyed by fd
+	eventsC   chan Event
+	inputDir  string
+	filter    *Filter
+	trace     *TraceWriter // records raw events when set
+	rescan    atomic.Bool  // set before waking the reader to re-apply the filter
+	stopping  atomic.Bool
+	watcher   *watcher
+	poller    *poller
+	probe     func(path string) (DeviceInfo, error) // reads a hotplugged node
+	wg        sync.WaitGroup
+	mu        sync.RWMutex
+	stopOnce  sync.Once
+	lastCheck time.Time
+}
+
+// Event is a single key event
+type Event struct {
+	Device string    // Device name
+	Path   string    // Device node path
+	Code   uint16    // Key code
+	Value  int32     // KEY_PRESSED, KEY_RELEASED or KEY_REPEAT
+	Time   time.Time // Kernel timestamp
+
+	// Snapshot is the pressed key state after this event, as seen by the
+	// matcher under the cross-device policy
+	Snapshot hotkey.Snapshot
+
+	// Typed is the typed buffer after this event, so expansions match what
+	// was typed then even if the consumer falls behind
+	Typed string
 }
 
-func NewListener(inputDir string) *Listener {
+// device is an open keyboard read through the poller
+type device struct {
+	fd      int
+	path    string
+	name    string
+	dropped bool // events are being discarded until the next SYN_REPORT
+}
+
+func NewListener(inputDir string, devices config.Devices) *Listener {
 	return &Listener{
-		eventsC:  make(chan uint16, 100),
+		state:    newKeyState(devices.CrossDevice == config.CrossDeviceIsolate),
+		devices:  make(map[int]*device),
+		eventsC:  make(chan Event, 100),
 		inputDir: inputDir,
+		filter:   NewFilter(devices),
+		probe:    probeDevice,
 	}
 }
 
+// Record writes every device and raw event to a trace. It must be called
+// before Start.
+func (l *Listener) Record(trace *TraceWriter) {
+	l.trace = trace
+}
+
 func (l *Listener) Start(ctx context.Context) error {
-	keyboards, err := findKeyboards(l.inputDir)
+	p, err := newPoller()
+	if err != nil {
+		return fmt.Errorf("create poller: %w", err)
+	}
+	l.poller = p
+
+	// Watch before scanning so devices plugged in between are not missed
+	w, err := newWatcher(l.inputDir)
+	if err != nil {
+		p.close()
+		return fmt.Errorf("watch %s: %w", l.inputDir, err)
+	}
+	l.watcher = w
+
+	if err := p.add(w.fd); err != nil {
+		w.Close()
+		p.close()
+		return err
+	}
+
+	keyboards, err := l.findKeyboards()
 	if err != nil {
+		w.Close()
+		p.close()
 		return err
 	}
 
 	if len(keyboards) == 0 {
-		return fmt.Errorf("no keyboards found")
+		fmt.Println("No keyboards found, waiting for devices...")
 	}
 
-	for _, path := range keyboards {
-		device, err := evdev.Open(path)
-		if err != nil {
-			return err
+	for _, info := range keyboards {
+		if err := l.addDevice(info); err != nil {
+			log.Printf("Warning: could not open %s: %v", info.Path, err)
 		}
+	}
 
-		// Set non-blocking mode so Close() can interrupt ReadOne()
-		if err := device.NonBlock(); err != nil {
-			device.Close()
-			return err
-		}
+	l.wg.Add(1)
+	go l.run()
 
-		l.devices = append(l.devices, device)
+	// Wake the reader when the context is cancelled
+	go func() {
+		<-ctx.Done()
+		l.stopping.Store(true)
+		p.wake()
+	}()
 
-		devName, err := device.Name()
+	return nil
+}
+
+// run is the single reader for every device and the hotplug watcher
+func (l *Listener) run() {
+	defer l.wg.Done()
+
+	l.lastCheck = time.Now()
+	events := make([]syscall.EpollEvent, 16)
+	for {
+		ready, woken, err := l.poller.wait(events, sanityInterval)
 		if err != nil {
-			return err
+			log.Printf("Warning: listener stopped: %v", err)
+			return
+		}
+		if woken {
+			if l.stopping.Load() {
+				return
+			}
+			if l.rescan.Swap(false) {
+				l.applyFilter()
+			}
+		}
+
+		if time.Since(l.lastCheck) >= sanityInterval {
+			l.sanityCheck()
 		}
 
-		fmt.Printf("Listening: %s\n", devName)
-		go l.readDevice(ctx, device)
+		for _, fd := range ready {
+			if fd == l.watcher.fd {
+				l.handleDeviceChanges()
+				continue
+			}
+			l.readDevice(fd)
+		}
 	}
+}
 
-	return nil
+// hasDevice reports whether the node at path is already open
+func (l *Listener) hasDevice(path string) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	for _, d := range l.devices {
+		if d.path == path {
+			return true
+		}
+	}
+	return false
 }
 
-func (l *Listener) read(ctx context.Context, device *evdev.InputDevice) error {
-	events, err := device.ReadSlice(1)
+// addDevice opens a keyboard and adds it to the poller
+func (l *Listener) addDevice(info DeviceInfo) error {
+	path, devName := info.Path, info.Name
+	if l.hasDevice(path) {
+		return nil
+	}
+
+	fd, err := syscall.Open(path, syscall.O_RDONLY|syscall.O_NONBLOCK|syscall.O_CLOEXEC, 0)
 	if err != nil {
 		return err
 	}
 
-	for _, ev := range events {
-		if ctx.Err() != nil {
-			return ctx.Err()
+	if err := l.poller.add(fd); err != nil {
+		syscall.Close(fd)
+		return err
+	}
+
+	d := &device{fd: fd, path: path, name: devName}
+
+	l.mu.Lock()
+	l.devices[fd] = d
+	l.mu.Unlock()
+
+	if l.trace != nil {
+		if err := l.trace.WriteDevice(info); err != nil {
+			log.Printf("Warning: trace %s: %v", devName, err)
+		}
+	}
+
+	// Keys may already be held when the device appears
+	l.state.addDevice(path, devName)
+	l.resyncDevice(d)
+
+	fmt.Printf("Listening: %s\n", devName)
+	return nil
+}
+
+// removeDevice stops reading from a keyboard and closes it
+func (l *Listener) removeDevice(path string) {
+	l.mu.Lock()
+	var removed *device
+	for fd, d := range l.devices {
+		if d.path == path {
+			removed = d
+			delete(l.devices, fd)
+			break
 		}
+	}
+	l.mu.Unlock()
+
+	if removed == nil {
+		return
+	}
+
+	l.closeDevice(removed)
+	l.state.removeDevice(path)
+	fmt.Printf("Removed: %s\n", removed.name)
+}
+
+// openDevices returns the devices currently read from
+func (l *Listener) openDevices() []*device {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	devices := make([]*device, 0, len(l.devices))
+	for _, d := range l.devices {
+		devices = append(devices, d)
+	}
+	return devices
+}
+
+func (l *Listener) closeDevice(d *device) {
+	l.poller.remove(d.fd)
+	syscall.Close(d.fd)
+}
 
-		if ev.Type != hotkey.EV_KEY {
+// readDevice drains all pending events from a device. A device that has
+// been unplugged reports ENODEV, or end of file, and is dropped.
+func (l *Listener) readDevice(fd int) {
+	l.mu.RLock()
+	d, exists := l.devices[fd]
+	l.mu.RUnlock()
+	if !exists {
+		return
+	}
+
+	buf := make([]byte, eventSize*readBufferEvents)
+	for {
+		n, err := syscall.Read(fd, buf)
+		switch {
+		case err == syscall.EAGAIN:
+			return
+		case err == syscall.EINTR:
 			continue
+		case err != nil:
+			if err != syscall.ENODEV {
+				log.Printf("Warning: read %s: %v", d.name, err)
+			}
+			l.removeDevice(d.path)
+			return
+		case n == 0:
+			l.removeDevice(d.path)
+			return
 		}
 
-		code := uint16(ev.Code)
+		events, err := decodeEvents(buf[:n])
+		if err != nil {
+			log.Printf("Warning: decode %s: %v", d.name, err)
+			return
+		}
+		l.handleEvents(d, events)
+	}
+}
 
-		l.mu.Lock()
-		switch ev.Value {
-		case hotkey.KEY_PRESSED:
-			l.pressed = append(l.pressed, code)
-			// Notify about key press
-			select {
-			case l.eventsC <- code:
-			default:
+func (l *Listener) handleEvents(d *device, events []evdev.InputEvent) {
+	for _, ev := range events {
+		if l.trace != nil {
+			if err := l.trace.WriteEvent(d.path, ev); err != nil {
+				log.Printf("Warning: trace %s: %v", d.name, err)
 			}
-		case hotkey.KEY_RELEASED:
-			idx := slices.IndexFunc(l.pressed, func(code uint16) bool {
-				return code == uint16(ev.Code)
-			})
-			if idx != -1 {
-				l.pressed = append(l.pressed[:idx], l.pressed[idx+1:]...)
+		}
+
+		// The kernel buffer overflowed, everything up to the next report is
+		// incomplete. Discard it and ask the kernel for the real key state.
+		if ev.Type == evdev.EV_SYN {
+			switch {
+			case ev.Code == evdev.SYN_DROPPED:
+				d.dropped = true
+			case ev.Code == evdev.SYN_REPORT && d.dropped:
+				d.dropped = false
+				l.resyncDevice(d)
 			}
+			continue
 		}
-		l.mu.Unlock()
-	}
 
-	return nil
-}
+		if d.dropped || ev.Type != hotkey.EV_KEY {
+			continue
+		}
 
-func (l *Listener) readDevice(ctx context.Context, device *evdev.InputDevice) {
-	for {
+		event := l.state.apply(d.path, uint16(ev.Code), ev.Value, time.Unix(ev.Time.Sec, ev.Time.Usec*1000))
+
+		// Notify about key event
 		select {
-		case <-ctx.Done():
-			return
+		case l.eventsC <- event:
 		default:
-			if err := l.read(ctx, device); err != nil {
-				continue
-			}
 		}
 	}
 }
 
-func findKeyboards(inputDir string) ([]string, error) {
-	pattern := filepath.Join(inputDir, "event*")
-	matches, err := filepath.Glob(pattern)
+// eventSize is the size of a kernel input_event
+var eventSize = binary.Size(evdev.InputEvent{})
+
+// decodeEvents decodes raw input_event structs as read from a device node
+func decodeEvents(buf []byte) ([]evdev.InputEvent, error) {
+	events := make([]evdev.InputEvent, len(buf)/eventSize)
+	if len(events) == 0 {
+		return nil, nil
+	}
+
+	reader := bytes.NewReader(buf[:len(events)*eventSize])
+	if err := binary.Read(reader, binary.LittleEndian, &events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// findKeyboards returns the device nodes accepted by the filter
+func (l *Listener) findKeyboards() ([]DeviceInfo, error) {
+	devices, err := ListDevices(l.inputDir)
 	if err != nil {
 		return nil, err
 	}
 
-	var keyboards []string
-	for _, path := range matches {
-		if isKeyboard(path) {
-			keyboards = append(keyboards, path)
+	filter := l.currentFilter()
+
+	var keyboards []DeviceInfo
+	for _, info := range devices {
+		if info.Err == nil && filter.Accept(info) {
+			keyboards = append(keyboards, info)
 		}
 	}
 	return keyboards, nil
 }
 
-func isKeyboard(path string) bool {
-	device, err := evdev.Open(path)
+// UpdateDevices replaces the device filter and cross-device policy and
+// re-applies the filter to open and available devices (Thread-Safe)
+func (l *Listener) UpdateDevices(devices config.Devices) {
+	l.mu.Lock()
+	l.filter = NewFilter(devices)
+	l.mu.Unlock()
+	l.state.setIsolate(devices.CrossDevice == config.CrossDeviceIsolate)
+
+	l.rescan.Store(true)
+	if l.poller != nil {
+		l.poller.wake()
+	}
+}
+
+func (l *Listener) currentFilter() *Filter {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.filter
+}
+
+// applyFilter closes devices the filter now rejects and opens newly accepted ones
+func (l *Listener) applyFilter() {
+	keyboards, err := l.findKeyboards()
 	if err != nil {
+		log.Printf("Warning: rescan devices: %v", err)
+		return
+	}
+
+	accepted := map[string]bool{}
+	for _, info := range keyboards {
+		accepted[info.Path] = true
+		if err := l.addDevice(info); err != nil {
+			log.Printf("Warning: could not open %s: %v", info.Path, err)
+		}
+	}
+
+	l.mu.RLock()
+	var rejected []string
+	for _, d := range l.devices {
+		if !accepted[d.path] {
+			rejected = append(rejected, d.path)
+		}
+	}
+	l.mu.RUnlock()
+
+	for _, path := range rejected {
+		l.removeDevice(path)
+	}
+}
+
+// isKeyboard is the default heuristic used when no device rule applies
+func isKeyboard(device *evdev.InputDevice, name string) bool {
+	// Skip virtual devices created by ghkd itself, like the expansion keyboard
+	if strings.HasPrefix(name, hotkey.VirtualDevicePrefix) {
 		return false
 	}
-	defer device.Close()
 
 	// Get supported keys for EV_KEY type
 	codes := device.CapableEvents(evdev.EV_KEY)
@@ -152,23 +433,49 @@ func isKeyboard(path string) bool {
 	return false
 }
 
+// PressedKeys returns the keys held on any device, in press order
 func (l *Listener) PressedKeys() []uint16 {
-	l.mu.RLock()
-	defer l.mu.RUnlock()
+	return l.state.merged()
+}
+
+// TypedText returns the recently typed characters, oldest first
+func (l *Listener) TypedText() string {
+	return l.state.typedText()
+}
 
-	destination := make([]uint16, len(l.pressed))
-	copy(destination, l.pressed)
-	return destination
+// ResetTyped clears the typed buffer
+func (l *Listener) ResetTyped() {
+	l.state.resetTyped()
 }
 
-func (l *Listener) Events() <-chan uint16 {
+// Events returns key presses, releases and repeats from every device
+func (l *Listener) Events() <-chan Event {
 	return l.eventsC
 }
 
+// Stop wakes the reader, waits for it to exit and closes every device
 func (l *Listener) Stop() {
-	for _, dev := range l.devices {
-		dev.Close()
-	}
+	l.stopOnce.Do(func() {
+		l.stopping.Store(true)
+		if l.poller != nil {
+			l.poller.wake()
+		}
+		l.wg.Wait()
+
+		l.mu.Lock()
+		for fd, d := range l.devices {
+			l.closeDevice(d)
+			delete(l.devices, fd)
+		}
+		l.mu.Unlock()
+
+		if l.watcher != nil {
+			l.watcher.Close()
+		}
+		if l.poller != nil {
+			l.poller.close()
+		}
 
-	close(l.eventsC)
+		close(l.eventsC)
+	})
 }
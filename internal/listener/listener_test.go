@@ -0,0 +1,136 @@
+package listener
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/glowfi/ghkd/internal/config"
+	"github.com/glowfi/ghkd/internal/hotkey"
+	"github.com/holoplot/go-evdev"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDevice opens a FIFO in place of a device node and returns its read
+// fd and a writer for raw input events
+func fakeDevice(t *testing.T, path string) (int, *os.File) {
+	t.Helper()
+	require.NoError(t, syscall.Mkfifo(path, 0o600))
+	fd, err := syscall.Open(path, syscall.O_RDONLY|syscall.O_NONBLOCK|syscall.O_CLOEXEC, 0)
+	require.NoError(t, err)
+	writer, err := os.OpenFile(path, os.O_WRONLY, 0)
+	require.NoError(t, err)
+	return fd, writer
+}
+
+func TestListener_ReadDevice(t *testing.T) {
+	tests := []struct {
+		name      string
+		events    []evdev.InputEvent
+		unplug    bool // the device goes away before it is read
+		wantCodes []uint16
+		wantOpen  bool
+	}{
+		{
+			name: "should deliver the key events of a device :POS",
+			events: []evdev.InputEvent{
+				{Type: evdev.EV_KEY, Code: evdev.EvCode(hotkey.KEY_A), Value: hotkey.KEY_PRESSED},
+				{Type: evdev.EV_SYN, Code: evdev.SYN_REPORT},
+			},
+			wantCodes: []uint16{hotkey.KEY_A},
+			wantOpen:  true,
+		},
+		{
+			name:   "should drop a device that went away :NEG",
+			unplug: true,
+		},
+	}
+
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "event1")
+		l := NewListener(filepath.Dir(path), config.Devices{})
+		p, err := newPoller()
+		require.NoError(t, err, tt.name)
+		l.poller = p
+
+		fd, writer := fakeDevice(t, path)
+		require.NoError(t, p.add(fd), tt.name)
+		l.devices[fd] = &device{fd: fd, path: path, name: "Fake Keyboard"}
+		l.state.addDevice(path, "Fake Keyboard")
+
+		var buf bytes.Buffer
+		require.NoError(t, binary.Write(&buf, binary.LittleEndian, tt.events), tt.name)
+		_, err = writer.Write(buf.Bytes())
+		require.NoError(t, err, tt.name)
+		if tt.unplug {
+			writer.Close()
+		}
+
+		l.readDevice(fd)
+
+		var codes []uint16
+		for len(l.eventsC) > 0 {
+			codes = append(codes, (<-l.eventsC).Code)
+		}
+		assert.Equal(t, tt.wantCodes, codes, tt.name)
+		assert.Equal(t, tt.wantOpen, l.hasDevice(path), tt.name)
+
+		for _, d := range l.openDevices() {
+			l.closeDevice(d)
+		}
+		writer.Close()
+		p.close()
+	}
+}
+
+func TestListener_HandleEvents(t *testing.T) {
+	key := func(code uint16, value int32) evdev.InputEvent {
+		return evdev.InputEvent{Type: evdev.EV_KEY, Code: evdev.EvCode(code), Value: value}
+	}
+	syn := func(code evdev.EvCode) evdev.InputEvent {
+		return evdev.InputEvent{Type: evdev.EV_SYN, Code: code}
+	}
+
+	tests := []struct {
+		name      string
+		events    []evdev.InputEvent
+		wantCodes []uint16
+	}{
+		{
+			name:      "should deliver key events :POS",
+			events:    []evdev.InputEvent{key(hotkey.KEY_A, hotkey.KEY_PRESSED), syn(evdev.SYN_REPORT)},
+			wantCodes: []uint16{hotkey.KEY_A},
+		},
+		{
+			name: "should discard events up to the report after SYN_DROPPED :NEG",
+			events: []evdev.InputEvent{
+				syn(evdev.SYN_DROPPED),
+				key(hotkey.KEY_A, hotkey.KEY_PRESSED),
+				syn(evdev.SYN_REPORT),
+				key(hotkey.KEY_B, hotkey.KEY_PRESSED),
+				syn(evdev.SYN_REPORT),
+			},
+			wantCodes: []uint16{hotkey.KEY_B},
+		},
+	}
+
+	for _, tt := range tests {
+		l := NewListener(t.TempDir(), config.Devices{})
+		// No real device, the resync after the report finds no kernel state
+		d := &device{fd: -1, path: "/dev/input/event3", name: "AT keyboard"}
+		l.state.addDevice(d.path, d.name)
+
+		l.handleEvents(d, tt.events)
+
+		var codes []uint16
+		for len(l.eventsC) > 0 {
+			codes = append(codes, (<-l.eventsC).Code)
+		}
+		assert.Equal(t, tt.wantCodes, codes, tt.name)
+		assert.Equal(t, tt.wantCodes, l.state.merged(), tt.name)
+	}
+}
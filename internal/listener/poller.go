@@ -0,0 +1,91 @@
+package listener
+
+import (
+	"syscall"
+	"time"
+)
+
+// poller waits on a set of file descriptors with epoll. A self-pipe lets
+// Stop() wake the reader without closing descriptors under it.
+type poller struct {
+	epfd  int
+	wakeR int
+	wakeW int
+}
+
+func newPoller() (*poller, error) {
+	epfd, err := syscall.EpollCreate1(syscall.EPOLL_CLOEXEC)
+	if err != nil {
+		return nil, err
+	}
+
+	var pipe [2]int
+	if err := syscall.Pipe2(pipe[:], syscall.O_NONBLOCK|syscall.O_CLOEXEC); err != nil {
+		syscall.Close(epfd)
+		return nil, err
+	}
+
+	p := &poller{epfd: epfd, wakeR: pipe[0], wakeW: pipe[1]}
+	if err := p.add(p.wakeR); err != nil {
+		p.close()
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// add starts watching fd for readability
+func (p *poller) add(fd int) error {
+	event := syscall.EpollEvent{Events: syscall.EPOLLIN, Fd: int32(fd)}
+	return syscall.EpollCtl(p.epfd, syscall.EPOLL_CTL_ADD, fd, &event)
+}
+
+// remove stops watching fd
+func (p *poller) remove(fd int) error {
+	return syscall.EpollCtl(p.epfd, syscall.EPOLL_CTL_DEL, fd, nil)
+}
+
+// wait blocks until at least one fd is ready or the timeout expires and
+// returns the ready fds. woken is true when wake() was called.
+func (p *poller) wait(events []syscall.EpollEvent, timeout time.Duration) (ready []int, woken bool, err error) {
+	for {
+		n, err := syscall.EpollWait(p.epfd, events, int(timeout.Milliseconds()))
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil {
+			return nil, false, err
+		}
+
+		for _, ev := range events[:n] {
+			if int(ev.Fd) == p.wakeR {
+				p.drainWake()
+				woken = true
+				continue
+			}
+			ready = append(ready, int(ev.Fd))
+		}
+		return ready, woken, nil
+	}
+}
+
+// drainWake empties the wake pipe so it stops reporting readable
+func (p *poller) drainWake() {
+	buf := make([]byte, 64)
+	for {
+		if n, err := syscall.Read(p.wakeR, buf); n <= 0 || err != nil {
+			return
+		}
+	}
+}
+
+// wake interrupts a pending wait
+func (p *poller) wake() {
+	syscall.Write(p.wakeW, []byte{0})
+}
+
+func (p *poller) close() {
+	syscall.Close(p.wakeR)
+	syscall.Close(p.wakeW)
+	syscall.Close(p.epfd)
+}
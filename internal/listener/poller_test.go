@@ -0,0 +1,73 @@
+package listener
+
+import (
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPoller_Wait(t *testing.T) {
+	tests := []struct {
+		name      string
+		write     bool // data is pending on the watched pipe
+		remove    bool // the pipe is removed before waiting
+		wake      bool
+		wantReady bool
+		wantWoken bool
+	}{
+		{
+			name:      "should report a readable fd :POS",
+			write:     true,
+			wantReady: true,
+		},
+		{
+			name:      "should report a wake up :POS",
+			wake:      true,
+			wantWoken: true,
+		},
+		{
+			name:   "should not report a removed fd :NEG",
+			write:  true,
+			remove: true,
+		},
+		{
+			name: "should time out without events :NEG",
+		},
+	}
+
+	for _, tt := range tests {
+		p, err := newPoller()
+		require.NoError(t, err, tt.name)
+
+		var pipe [2]int
+		require.NoError(t, syscall.Pipe2(pipe[:], syscall.O_NONBLOCK|syscall.O_CLOEXEC), tt.name)
+		require.NoError(t, p.add(pipe[0]), tt.name)
+
+		if tt.write {
+			_, err := syscall.Write(pipe[1], []byte{1})
+			require.NoError(t, err, tt.name)
+		}
+		if tt.remove {
+			require.NoError(t, p.remove(pipe[0]), tt.name)
+		}
+		if tt.wake {
+			p.wake()
+		}
+
+		ready, woken, err := p.wait(make([]syscall.EpollEvent, 4), 10*time.Millisecond)
+		require.NoError(t, err, tt.name)
+		if tt.wantReady {
+			assert.Equal(t, []int{pipe[0]}, ready, tt.name)
+		} else {
+			assert.Empty(t, ready, tt.name)
+		}
+		assert.Equal(t, tt.wantWoken, woken, tt.name)
+
+		syscall.Close(pipe[0])
+		syscall.Close(pipe[1])
+		p.close()
+	}
+}
@@ -0,0 +1,140 @@
+package listener
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/glowfi/ghkd/internal/config"
+	"github.com/glowfi/ghkd/internal/hotkey"
+	"github.com/holoplot/go-evdev"
+)
+
+// Replay is a Source that plays back a recorded trace instead of reading
+// devices. Device filters don't apply, every record in the trace is used.
+type Replay struct {
+	reader   *TraceReader
+	state    *keyState
+	eventsC  chan Event
+	pace     bool
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+	stopOnce sync.Once
+}
+
+// NewReplay creates a replay of the trace in r. With pace set, records are
+// delivered with the delays between their timestamps, otherwise at once.
+func NewReplay(r io.Reader, devices config.Devices, pace bool) *Replay {
+	return &Replay{
+		reader:  NewTraceReader(r),
+		state:   newKeyState(devices.CrossDevice == config.CrossDeviceIsolate),
+		eventsC: make(chan Event, 100),
+		pace:    pace,
+	}
+}
+
+func (r *Replay) Start(ctx context.Context) error {
+	ctx, r.cancel = context.WithCancel(ctx)
+
+	r.wg.Add(1)
+	go r.run(ctx)
+
+	return nil
+}
+
+// run delivers every record and closes Events() at the end of the trace
+func (r *Replay) run(ctx context.Context) {
+	defer r.wg.Done()
+	defer close(r.eventsC)
+
+	var last time.Time
+	dropped := map[string]bool{}
+
+	for {
+		record, err := r.reader.Next()
+		if errors.Is(err, io.EOF) {
+			return
+		}
+		if err != nil {
+			log.Printf("Warning: replay stopped: %v", err)
+			return
+		}
+
+		when := record.When()
+		if r.pace && !last.IsZero() && when.After(last) {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(when.Sub(last)):
+			}
+		}
+		last = when
+
+		if record.Info != nil {
+			r.state.addDevice(record.Device, record.Info.Name)
+			continue
+		}
+
+		// No kernel state to resync from, so dropped events are just skipped
+		if record.Type == uint16(evdev.EV_SYN) {
+			switch record.Code {
+			case uint16(evdev.SYN_DROPPED):
+				dropped[record.Device] = true
+			case uint16(evdev.SYN_REPORT):
+				dropped[record.Device] = false
+			}
+			continue
+		}
+
+		if dropped[record.Device] || record.Type != hotkey.EV_KEY {
+			continue
+		}
+
+		event := r.state.apply(record.Device, record.Code, record.Value, when)
+
+		// Unlike a live device, a replay never drops events
+		select {
+		case <-ctx.Done():
+			return
+		case r.eventsC <- event:
+		}
+	}
+}
+
+// Events returns the replayed key events. It is closed at the end of the trace.
+func (r *Replay) Events() <-chan Event {
+	return r.eventsC
+}
+
+// PressedKeys returns the keys held on any device, in press order
+func (r *Replay) PressedKeys() []uint16 {
+	return r.state.merged()
+}
+
+// TypedText returns the recently typed characters, oldest first
+func (r *Replay) TypedText() string {
+	return r.state.typedText()
+}
+
+// ResetTyped clears the typed buffer
+func (r *Replay) ResetTyped() {
+	r.state.resetTyped()
+}
+
+// UpdateDevices applies the cross-device policy
+func (r *Replay) UpdateDevices(devices config.Devices) {
+	r.state.setIsolate(devices.CrossDevice == config.CrossDeviceIsolate)
+}
+
+// Stop ends the replay and waits for it to exit
+func (r *Replay) Stop() {
+	r.stopOnce.Do(func() {
+		if r.cancel != nil {
+			r.cancel()
+		}
+		r.wg.Wait()
+	})
+}
@@ -0,0 +1,113 @@
+package listener
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/glowfi/ghkd/internal/config"
+	"github.com/glowfi/ghkd/internal/hotkey"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplay_Snapshots(t *testing.T) {
+	tests := []struct {
+		name          string
+		tracePath     string
+		devices       config.Devices
+		wantSnapshots [][]uint16
+	}{
+		{
+			name:      "should merge keys held on different devices by default :POS",
+			tracePath: "./testdata/cross_device.jsonl",
+			devices:   config.Devices{},
+			wantSnapshots: [][]uint16{
+				{hotkey.KEY_LEFTCTRL},
+				{hotkey.KEY_LEFTCTRL, hotkey.KEY_T},
+				{hotkey.KEY_LEFTCTRL},
+				{},
+			},
+		},
+		{
+			name:      "should isolate keys held on different devices :POS",
+			tracePath: "./testdata/cross_device.jsonl",
+			devices:   config.Devices{CrossDevice: config.CrossDeviceIsolate},
+			wantSnapshots: [][]uint16{
+				{hotkey.KEY_LEFTCTRL},
+				{hotkey.KEY_T},
+				{},
+				{},
+			},
+		},
+		{
+			name:      "should skip events until the report after SYN_DROPPED :POS",
+			tracePath: "./testdata/dropped.jsonl",
+			devices:   config.Devices{},
+			wantSnapshots: [][]uint16{
+				{hotkey.KEY_LEFTALT},
+				{hotkey.KEY_LEFTALT, hotkey.KEY_T},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		file, err := os.Open(tt.tracePath)
+		assert.NoError(t, err, "expect trace to open")
+
+		replay := NewReplay(file, tt.devices, false)
+		assert.NoError(t, replay.Start(context.Background()), "expect replay to start")
+
+		var gotSnapshots [][]uint16
+		for ev := range replay.Events() {
+			gotSnapshots = append(gotSnapshots, ev.Snapshot.Keys)
+		}
+		replay.Stop()
+		file.Close()
+
+		assert.Equal(t, tt.wantSnapshots, gotSnapshots, "expect snapshots to match")
+	}
+}
+
+func TestReplay_Typed(t *testing.T) {
+	tests := []struct {
+		name      string
+		tracePath string
+		wantTyped []string
+	}{
+		{
+			name:      "should carry the typed text of each press and repeat :POS",
+			tracePath: "./testdata/typed.jsonl",
+			wantTyped: []string{";", ";s", ";si", ";sig", ";sig", ""},
+		},
+		{
+			name:      "should type a held key again on each repeat :POS",
+			tracePath: "./testdata/typed_repeat.jsonl",
+			wantTyped: []string{"-", "--", "---"},
+		},
+	}
+
+	for _, tt := range tests {
+		file, err := os.Open(tt.tracePath)
+		assert.NoError(t, err, "expect trace to open")
+
+		replay := NewReplay(file, config.Devices{}, false)
+		assert.NoError(t, replay.Start(context.Background()), "expect replay to start")
+
+		// Events are read after the whole trace was applied, like a
+		// consumer that fell behind
+		var events []Event
+		for ev := range replay.Events() {
+			events = append(events, ev)
+		}
+		replay.Stop()
+		file.Close()
+
+		var gotTyped []string
+		for _, ev := range events {
+			if ev.Value != hotkey.KEY_RELEASED {
+				gotTyped = append(gotTyped, ev.Typed)
+			}
+		}
+		assert.Equal(t, tt.wantTyped, gotTyped, tt.name)
+	}
+}
@@ -0,0 +1,59 @@
+package listener
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	// sanityInterval is how often pressed keys are checked against the kernel
+	sanityInterval = 5 * time.Second
+
+	// resumeThreshold is how far the wall clock may run ahead of the
+	// monotonic clock before we assume the system was suspended
+	resumeThreshold = 2 * time.Second
+)
+
+// resyncDevice replaces our view of a device's keys with the kernel's.
+// Keys the kernel reports as up are dropped and held keys we missed are
+// added.
+func (l *Listener) resyncDevice(d *device) {
+	down, err := kernelKeys(d.fd)
+	if err != nil {
+		log.Printf("Warning: key state %s: %v", d.name, err)
+		return
+	}
+	l.state.sync(d.path, down, true)
+}
+
+// pruneReleased drops pressed keys that the kernel reports as up
+func (l *Listener) pruneReleased() {
+	for _, d := range l.openDevices() {
+		down, err := kernelKeys(d.fd)
+		if err != nil {
+			continue
+		}
+		l.state.sync(d.path, down, false)
+	}
+}
+
+// sanityCheck clears stuck keys and resyncs after a system resume
+func (l *Listener) sanityCheck() {
+	now := time.Now()
+	monoElapsed := now.Sub(l.lastCheck)
+	wallElapsed := now.Round(0).Sub(l.lastCheck.Round(0))
+	l.lastCheck = now
+
+	// The monotonic clock stops during suspend, the wall clock does not
+	if wallElapsed-monoElapsed > resumeThreshold {
+		fmt.Println("System resumed, resynchronizing keys")
+		l.state.resetTyped()
+		for _, d := range l.openDevices() {
+			l.resyncDevice(d)
+		}
+		return
+	}
+
+	l.pruneReleased()
+}
@@ -0,0 +1,24 @@
+package listener
+
+import (
+	"context"
+
+	"github.com/glowfi/ghkd/internal/config"
+)
+
+// Source delivers key events to the event loop. Listener reads them from
+// evdev devices, Replay from a recorded trace.
+type Source interface {
+	Start(ctx context.Context) error
+	Events() <-chan Event
+	PressedKeys() []uint16
+	TypedText() string
+	ResetTyped()
+	UpdateDevices(devices config.Devices)
+	Stop()
+}
+
+var (
+	_ Source = (*Listener)(nil)
+	_ Source = (*Replay)(nil)
+)
@@ -0,0 +1,256 @@
+package listener
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/glowfi/ghkd/internal/hotkey"
+)
+
+// typedBufferSize is the number of recently typed characters kept for expansions
+const typedBufferSize = 64
+
+// keyState tracks the keys held on each device and the recently typed text.
+// It is shared by every Source so they agree on what the matcher sees.
+type keyState struct {
+	mu      sync.RWMutex
+	devices map[string]*deviceKeys // keyed by device path
+	seq     uint64                 // press counter ordering keys across devices
+	typed   []rune
+	isolate bool // combos only match keys held on a single device
+}
+
+// deviceKeys is the keys held on one device
+type deviceKeys struct {
+	name    string
+	pressed []pressedKey
+}
+
+// pressedKey is a held key and the order in which it was pressed across
+// all devices
+type pressedKey struct {
+	code uint16
+	seq  uint64
+}
+
+func newKeyState(isolate bool) *keyState {
+	return &keyState{
+		devices: make(map[string]*deviceKeys),
+		isolate: isolate,
+	}
+}
+
+// setIsolate changes the cross-device policy
+func (s *keyState) setIsolate(isolate bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.isolate = isolate
+}
+
+// addDevice starts tracking a device with no keys held
+func (s *keyState) addDevice(path, name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.devices[path]; !exists {
+		s.devices[path] = &deviceKeys{name: name}
+	}
+}
+
+// removeDevice forgets a device and every key held on it
+func (s *keyState) removeDevice(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.devices, path)
+}
+
+// apply records a key event from a device and returns it with the
+// resulting snapshot
+func (s *keyState) apply(path string, code uint16, value int32, t time.Time) Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d, exists := s.devices[path]
+	if !exists {
+		d = &deviceKeys{name: path}
+		s.devices[path] = d
+	}
+
+	switch value {
+	case hotkey.KEY_PRESSED:
+		s.recordTyped(code, s.heldKeys(d))
+		s.press(d, code)
+	case hotkey.KEY_REPEAT:
+		// A held key keeps typing, as in "--" or "::"
+		s.recordTyped(code, s.heldKeys(d))
+	case hotkey.KEY_RELEASED:
+		d.pressed = slices.DeleteFunc(d.pressed, func(k pressedKey) bool {
+			return k.code == code
+		})
+	}
+
+	return Event{
+		Device: d.name,
+		Path:   path,
+		Code:   code,
+		Value:  value,
+		Time:   t,
+		Snapshot: hotkey.Snapshot{
+			Keys:       s.heldKeys(d),
+			Device:     d.name,
+			DevicePath: path,
+		},
+		Typed: string(s.typed),
+	}
+}
+
+// sync replaces a device's keys with the kernel's view. Keys not in down
+// are dropped. Keys in down that we missed are added when addMissing is set.
+func (s *keyState) sync(path string, down []uint16, addMissing bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d, exists := s.devices[path]
+	if !exists {
+		return
+	}
+
+	before := len(d.pressed)
+	d.pressed = slices.DeleteFunc(d.pressed, func(k pressedKey) bool {
+		return !slices.Contains(down, k.code)
+	})
+	if stale := before - len(d.pressed); stale > 0 {
+		fmt.Printf("Cleared %d stuck key(s) on %s\n", stale, d.name)
+	}
+
+	if !addMissing {
+		return
+	}
+
+	// Modifiers go first so the recovered keys still match combos
+	down = slices.Clone(down)
+	slices.SortStableFunc(down, func(a, b uint16) int {
+		return modifierRank(a) - modifierRank(b)
+	})
+	for _, code := range down {
+		s.press(d, code)
+	}
+}
+
+// press records a key as held on the device. Must be called with s.mu held.
+func (s *keyState) press(d *deviceKeys, code uint16) {
+	if slices.ContainsFunc(d.pressed, func(k pressedKey) bool { return k.code == code }) {
+		return
+	}
+	s.seq++
+	d.pressed = append(d.pressed, pressedKey{code: code, seq: s.seq})
+}
+
+// merged returns the keys held on any device, in press order
+func (s *keyState) merged() []uint16 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.mergedKeys()
+}
+
+// mergedKeys returns the keys held on any device, in press order. Must be
+// called with s.mu held.
+func (s *keyState) mergedKeys() []uint16 {
+	var all []pressedKey
+	for _, d := range s.devices {
+		all = append(all, d.pressed...)
+	}
+	slices.SortFunc(all, func(a, b pressedKey) int {
+		return cmp.Compare(a.seq, b.seq)
+	})
+
+	codes := make([]uint16, 0, len(all))
+	for _, k := range all {
+		// The same key may be held on two devices
+		if !slices.Contains(codes, k.code) {
+			codes = append(codes, k.code)
+		}
+	}
+	return codes
+}
+
+// heldKeys returns the keys that count as held together with keys from d
+// under the cross-device policy. Must be called with s.mu held.
+func (s *keyState) heldKeys(d *deviceKeys) []uint16 {
+	if !s.isolate {
+		return s.mergedKeys()
+	}
+
+	codes := make([]uint16, len(d.pressed))
+	for i, k := range d.pressed {
+		codes[i] = k.code
+	}
+	return codes
+}
+
+// recordTyped appends the character produced by a key press to the typed
+// buffer, given the keys held before it. Must be called with s.mu held.
+func (s *keyState) recordTyped(code uint16, held []uint16) {
+	name, _ := hotkey.LookupKeyName(code)
+	if hotkey.IsModifier(name) {
+		return
+	}
+
+	if code == hotkey.KEY_BACKSPACE {
+		if len(s.typed) > 0 {
+			s.typed = s.typed[:len(s.typed)-1]
+		}
+		return
+	}
+
+	shift := false
+	for _, key := range held {
+		switch key {
+		case hotkey.KEY_LEFTSHIFT, hotkey.KEY_RIGHTSHIFT:
+			shift = true
+		case hotkey.KEY_LEFTCTRL, hotkey.KEY_RIGHTCTRL,
+			hotkey.KEY_LEFTALT, hotkey.KEY_RIGHTALT,
+			hotkey.KEY_LEFTMETA, hotkey.KEY_RIGHTMETA:
+			// Shortcuts are not typing
+			s.typed = s.typed[:0]
+			return
+		}
+	}
+
+	char, ok := hotkey.LookupChar(code, shift)
+	if !ok {
+		// Navigation and other keys move the cursor away from what was typed
+		s.typed = s.typed[:0]
+		return
+	}
+
+	s.typed = append(s.typed, char)
+	if len(s.typed) > typedBufferSize {
+		s.typed = s.typed[len(s.typed)-typedBufferSize:]
+	}
+}
+
+// typedText returns the recently typed characters, oldest first
+func (s *keyState) typedText() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return string(s.typed)
+}
+
+// resetTyped clears the typed buffer
+func (s *keyState) resetTyped() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.typed = s.typed[:0]
+}
+
+func modifierRank(code uint16) int {
+	name, _ := hotkey.LookupKeyName(code)
+	if hotkey.IsModifier(name) {
+		return 0
+	}
+	return 1
+}
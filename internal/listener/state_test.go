@@ -0,0 +1,73 @@
+package listener
+
+import (
+	"testing"
+	"time"
+
+	"github.com/glowfi/ghkd/internal/hotkey"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestState_Sync(t *testing.T) {
+	const path = "/dev/input/event3"
+
+	tests := []struct {
+		name       string
+		pressed    []uint16 // keys we saw pressed
+		syncPath   string
+		down       []uint16 // keys the kernel reports held
+		addMissing bool
+		want       []uint16
+	}{
+		{
+			name:       "should clear keys the kernel reports as up :POS",
+			pressed:    []uint16{hotkey.KEY_LEFTCTRL, hotkey.KEY_T},
+			syncPath:   path,
+			down:       []uint16{hotkey.KEY_LEFTCTRL},
+			addMissing: true,
+			want:       []uint16{hotkey.KEY_LEFTCTRL},
+		},
+		{
+			name:       "should add missed keys with modifiers first :POS",
+			syncPath:   path,
+			down:       []uint16{hotkey.KEY_T, hotkey.KEY_LEFTCTRL},
+			addMissing: true,
+			want:       []uint16{hotkey.KEY_LEFTCTRL, hotkey.KEY_T},
+		},
+		{
+			name:       "should keep the press order of keys still held :POS",
+			pressed:    []uint16{hotkey.KEY_T, hotkey.KEY_LEFTCTRL},
+			syncPath:   path,
+			down:       []uint16{hotkey.KEY_LEFTCTRL, hotkey.KEY_T},
+			addMissing: true,
+			want:       []uint16{hotkey.KEY_T, hotkey.KEY_LEFTCTRL},
+		},
+		{
+			name:       "should only clear keys when pruning :NEG",
+			pressed:    []uint16{hotkey.KEY_LEFTCTRL, hotkey.KEY_A},
+			syncPath:   path,
+			down:       []uint16{hotkey.KEY_LEFTCTRL, hotkey.KEY_T},
+			addMissing: false,
+			want:       []uint16{hotkey.KEY_LEFTCTRL},
+		},
+		{
+			name:       "should ignore devices it doesn't track :NEG",
+			pressed:    []uint16{hotkey.KEY_LEFTCTRL},
+			syncPath:   "/dev/input/event9",
+			down:       []uint16{hotkey.KEY_T},
+			addMissing: true,
+			want:       []uint16{hotkey.KEY_LEFTCTRL},
+		},
+	}
+
+	for _, tt := range tests {
+		s := newKeyState(false)
+		s.addDevice(path, "AT keyboard")
+		for _, code := range tt.pressed {
+			s.apply(path, code, hotkey.KEY_PRESSED, time.Now())
+		}
+
+		s.sync(tt.syncPath, tt.down, tt.addMissing)
+		assert.Equal(t, tt.want, s.merged(), tt.name)
+	}
+}
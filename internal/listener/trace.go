@@ -0,0 +1,186 @@
+package listener
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/glowfi/ghkd/internal/hotkey"
+	"github.com/holoplot/go-evdev"
+)
+
+// TraceRecord is one line of a JSON-lines trace file. It is either an
+// input event or, when Info is set, the metadata of a device:
+//
+//	{"time": 1700000000.1, "device": "/dev/input/event3", "info": {"name": "AT keyboard", ...}}
+//	{"time": 1700000000.2, "device": "/dev/input/event3", "type": 1, "code": 30, "value": 1}
+type TraceRecord struct {
+	Time   float64      `json:"time"`           // Seconds since the epoch
+	Device string       `json:"device"`         // Device node path
+	Type   uint16       `json:"type"`           // Event type, EV_KEY is 1
+	Code   uint16       `json:"code"`           // Event code
+	Value  int32        `json:"value"`          // Event value
+	Info   *TraceDevice `json:"info,omitempty"` // Device metadata
+}
+
+// TraceDevice is the metadata of a device in a trace
+type TraceDevice struct {
+	Name    string `json:"name"`
+	Phys    string `json:"phys,omitempty"`
+	Bus     uint16 `json:"bus"`
+	Vendor  uint16 `json:"vendor"`
+	Product uint16 `json:"product"`
+	Version uint16 `json:"version"`
+}
+
+// traceTime converts a time to trace seconds
+func traceTime(t time.Time) float64 {
+	return float64(t.Unix()) + float64(t.Nanosecond()/1000)/1e6
+}
+
+// When returns the record time
+func (r TraceRecord) When() time.Time {
+	sec, frac := math.Modf(r.Time)
+	return time.Unix(int64(sec), int64(math.Round(frac*1e6))*1000)
+}
+
+// TraceReader reads records from a trace. Blank lines and lines starting
+// with # are skipped, so traces can be written by hand.
+type TraceReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func NewTraceReader(r io.Reader) *TraceReader {
+	return &TraceReader{scanner: bufio.NewScanner(r)}
+}
+
+// Next returns the next record, or io.EOF at the end of the trace
+func (t *TraceReader) Next() (TraceRecord, error) {
+	for t.scanner.Scan() {
+		t.line++
+
+		line := bytes.TrimSpace(t.scanner.Bytes())
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+
+		var record TraceRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			return TraceRecord{}, fmt.Errorf("trace line %d: %w", t.line, err)
+		}
+		return record, nil
+	}
+
+	if err := t.scanner.Err(); err != nil {
+		return TraceRecord{}, err
+	}
+	return TraceRecord{}, io.EOF
+}
+
+// TraceWriter records devices and raw input events as a trace
+type TraceWriter struct {
+	mu        sync.Mutex
+	encoder   *json.Encoder
+	mask      bool
+	modifiers map[string]map[uint16]bool // shortcut modifiers held per device
+}
+
+// NewTraceWriter creates a trace writer. With mask set, letter and number
+// keys are recorded as KEY_RESERVED unless ctrl, alt or super is held, so
+// typed text like passwords is not recorded but shortcuts still replay.
+// Scancode (EV_MSC) events are left out.
+func NewTraceWriter(w io.Writer, mask bool) *TraceWriter {
+	return &TraceWriter{
+		encoder:   json.NewEncoder(w),
+		mask:      mask,
+		modifiers: make(map[string]map[uint16]bool),
+	}
+}
+
+// WriteDevice records the metadata of a device
+func (t *TraceWriter) WriteDevice(info DeviceInfo) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.encoder.Encode(TraceRecord{
+		Time:   traceTime(time.Now()),
+		Device: info.Path,
+		Info: &TraceDevice{
+			Name:    info.Name,
+			Phys:    info.Phys,
+			Bus:     info.ID.BusType,
+			Vendor:  info.ID.Vendor,
+			Product: info.ID.Product,
+			Version: info.ID.Version,
+		},
+	})
+}
+
+// WriteEvent records a raw input event from a device
+func (t *TraceWriter) WriteEvent(path string, ev evdev.InputEvent) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// Scancodes come before their key event and would tell the key anyway
+	if ev.Type == evdev.EV_MSC && t.mask {
+		return nil
+	}
+
+	code := uint16(ev.Code)
+	if ev.Type == evdev.EV_KEY && t.mask {
+		code = t.maskCode(path, code, ev.Value)
+	}
+
+	return t.encoder.Encode(TraceRecord{
+		Time:   traceTime(time.Unix(ev.Time.Sec, ev.Time.Usec*1000)),
+		Device: path,
+		Type:   uint16(ev.Type),
+		Code:   code,
+		Value:  ev.Value,
+	})
+}
+
+// maskCode hides letter and number keys that are not part of a shortcut.
+// Must be called with t.mu held.
+func (t *TraceWriter) maskCode(path string, code uint16, value int32) uint16 {
+	held, exists := t.modifiers[path]
+	if !exists {
+		held = make(map[uint16]bool)
+		t.modifiers[path] = held
+	}
+
+	// Right alt is AltGr on many layouts and types text like @ and €, it
+	// doesn't make a shortcut
+	switch code {
+	case hotkey.KEY_LEFTCTRL, hotkey.KEY_RIGHTCTRL,
+		hotkey.KEY_LEFTALT,
+		hotkey.KEY_LEFTMETA, hotkey.KEY_RIGHTMETA:
+		held[code] = value != hotkey.KEY_RELEASED
+		return code
+	}
+
+	if !isTextKey(code) {
+		return code
+	}
+	for _, down := range held {
+		if down {
+			return code
+		}
+	}
+	return evdev.KEY_RESERVED
+}
+
+// isTextKey reports whether a key types a letter or a number
+func isTextKey(code uint16) bool {
+	name, found := hotkey.LookupKeyName(code)
+	if !found || len(name) != 1 {
+		return false
+	}
+	return (name[0] >= 'a' && name[0] <= 'z') || (name[0] >= '0' && name[0] <= '9')
+}
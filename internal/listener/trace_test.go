@@ -0,0 +1,108 @@
+package listener
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/glowfi/ghkd/internal/hotkey"
+	"github.com/holoplot/go-evdev"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTraceWriter_Mask(t *testing.T) {
+	tests := []struct {
+		name      string
+		mask      bool
+		codes     []uint16 // pressed in order, then released in reverse
+		scancodes bool     // each key event follows an MSC_SCAN of the code
+		wantCodes []uint16
+		wantScans []int32 // values of the recorded scancode events
+	}{
+		{
+			name:      "should record every key without mask :POS",
+			mask:      false,
+			codes:     []uint16{hotkey.KEY_A, hotkey.KEY_1},
+			wantCodes: []uint16{hotkey.KEY_A, hotkey.KEY_1, hotkey.KEY_1, hotkey.KEY_A},
+		},
+		{
+			name:      "should mask typed letters and numbers :POS",
+			mask:      true,
+			codes:     []uint16{hotkey.KEY_LEFTSHIFT, hotkey.KEY_A, hotkey.KEY_1},
+			wantCodes: []uint16{hotkey.KEY_LEFTSHIFT, evdev.KEY_RESERVED, evdev.KEY_RESERVED, evdev.KEY_RESERVED, evdev.KEY_RESERVED, hotkey.KEY_LEFTSHIFT},
+		},
+		{
+			name:      "should keep shortcuts and other keys with mask :NEG",
+			mask:      true,
+			codes:     []uint16{hotkey.KEY_LEFTCTRL, hotkey.KEY_T, hotkey.KEY_ENTER},
+			wantCodes: []uint16{hotkey.KEY_LEFTCTRL, hotkey.KEY_T, hotkey.KEY_ENTER, hotkey.KEY_ENTER, hotkey.KEY_T, hotkey.KEY_LEFTCTRL},
+		},
+		{
+			name:      "should mask keys typed with AltGr :NEG",
+			mask:      true,
+			codes:     []uint16{hotkey.KEY_RIGHTALT, hotkey.KEY_Q},
+			wantCodes: []uint16{hotkey.KEY_RIGHTALT, evdev.KEY_RESERVED, evdev.KEY_RESERVED, hotkey.KEY_RIGHTALT},
+		},
+		{
+			name:      "should record scancodes without mask :POS",
+			mask:      false,
+			codes:     []uint16{hotkey.KEY_A},
+			scancodes: true,
+			wantCodes: []uint16{hotkey.KEY_A, hotkey.KEY_A},
+			wantScans: []int32{hotkey.KEY_A, hotkey.KEY_A},
+		},
+		{
+			name:      "should drop scancodes with mask :NEG",
+			mask:      true,
+			codes:     []uint16{hotkey.KEY_A},
+			scancodes: true,
+			wantCodes: []uint16{evdev.KEY_RESERVED, evdev.KEY_RESERVED},
+			wantScans: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		writer := NewTraceWriter(&buf, tt.mask)
+
+		assert.NoError(t, writer.WriteDevice(DeviceInfo{Path: "/dev/input/event3", Name: "AT keyboard"}), "expect device to be written")
+		writeKey := func(code uint16, value int32) {
+			if tt.scancodes {
+				assert.NoError(t, writer.WriteEvent("/dev/input/event3", evdev.InputEvent{Type: evdev.EV_MSC, Code: evdev.MSC_SCAN, Value: int32(code)}))
+			}
+			assert.NoError(t, writer.WriteEvent("/dev/input/event3", evdev.InputEvent{Type: evdev.EV_KEY, Code: evdev.EvCode(code), Value: value}))
+		}
+		for _, code := range tt.codes {
+			writeKey(code, hotkey.KEY_PRESSED)
+		}
+		for i := len(tt.codes) - 1; i >= 0; i-- {
+			writeKey(tt.codes[i], hotkey.KEY_RELEASED)
+		}
+
+		reader := NewTraceReader(&buf)
+		record, err := reader.Next()
+		assert.NoError(t, err, "expect device record")
+		assert.Equal(t, "AT keyboard", record.Info.Name, "expect device name to be recorded")
+
+		var (
+			gotCodes []uint16
+			gotScans []int32
+		)
+		for {
+			record, err := reader.Next()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			assert.NoError(t, err, "expect event record")
+			if record.Type == uint16(evdev.EV_MSC) {
+				gotScans = append(gotScans, record.Value)
+				continue
+			}
+			gotCodes = append(gotCodes, record.Code)
+		}
+
+		assert.Equal(t, tt.wantCodes, gotCodes, tt.name)
+		assert.Equal(t, tt.wantScans, gotScans, tt.name)
+	}
+}
@@ -0,0 +1,75 @@
+package registry
+
+import (
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/glowfi/ghkd/internal/config"
+)
+
+// CommandCheck runs a 'when' command of a binding and reports whether it
+// succeeded
+type CommandCheck func(kb *config.Keybinding, command string) bool
+
+// holds reports whether a binding's condition is met. The mode is checked
+// when the binding is picked as a candidate.
+func holds(kb *config.Keybinding, now time.Time, check CommandCheck) bool {
+	w := kb.When
+	if !w.Guarded() {
+		return true
+	}
+
+	if w.Time != "" && !inWindow(w.Time, now) {
+		return false
+	}
+	if w.Env != "" && !envMatches(kb, w.Env) {
+		return false
+	}
+	if w.File != "" {
+		if _, err := os.Stat(config.ExpandPath(w.File, kb.Env)); err != nil {
+			return false
+		}
+	}
+	if w.Command != "" && !check(kb, w.Command) {
+		return false
+	}
+	return true
+}
+
+// inWindow reports whether now is inside a "15:04-15:04" window
+func inWindow(window string, now time.Time) bool {
+	// Validated when the config was loaded
+	start, end, _ := config.ParseTimeWindow(window)
+
+	clock := time.Duration(now.Hour())*time.Hour + time.Duration(now.Minute())*time.Minute
+	if start <= end {
+		return clock >= start && clock < end
+	}
+	return clock >= start || clock < end
+}
+
+// envMatches checks "NAME", set and not empty, or "NAME=value". The
+// binding's env is looked at before the daemon's.
+func envMatches(kb *config.Keybinding, cond string) bool {
+	name, want, hasValue := strings.Cut(cond, "=")
+
+	value, found := kb.Env[name]
+	if !found {
+		value = os.Getenv(name)
+	}
+
+	if hasValue {
+		return value == want
+	}
+	return value != ""
+}
+
+// byPriority orders candidates by priority, keeping config order for equal
+// priorities
+func byPriority(candidates []*config.Keybinding) {
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Priority > candidates[j].Priority
+	})
+}
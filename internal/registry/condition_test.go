@@ -0,0 +1,113 @@
+package registry
+
+import (
+	"testing"
+	"time"
+
+	"github.com/glowfi/ghkd/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCondition_InWindow(t *testing.T) {
+	at := func(clock string) time.Time {
+		now, _ := time.Parse("15:04", clock)
+		return now
+	}
+
+	tests := []struct {
+		name   string
+		window string
+		now    time.Time
+		want   bool
+	}{
+		{
+			name:   "should hold inside a daytime window :POS",
+			window: "09:00-17:00",
+			now:    at("12:30"),
+			want:   true,
+		},
+		{
+			name:   "should hold at the start of a window :POS",
+			window: "09:00-17:00",
+			now:    at("09:00"),
+			want:   true,
+		},
+		{
+			name:   "should not hold at the end of a window :NEG",
+			window: "09:00-17:00",
+			now:    at("17:00"),
+			want:   false,
+		},
+		{
+			name:   "should hold before midnight in a wrapping window :POS",
+			window: "22:00-06:00",
+			now:    at("23:15"),
+			want:   true,
+		},
+		{
+			name:   "should hold after midnight in a wrapping window :POS",
+			window: "22:00-06:00",
+			now:    at("03:00"),
+			want:   true,
+		},
+		{
+			name:   "should not hold outside a wrapping window :NEG",
+			window: "22:00-06:00",
+			now:    at("12:00"),
+			want:   false,
+		},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, inWindow(tt.window, tt.now), tt.name)
+	}
+}
+
+func TestCondition_EnvMatches(t *testing.T) {
+	t.Setenv("GHKD_TEST_SESSION", "wayland")
+	t.Setenv("GHKD_TEST_EMPTY", "")
+
+	tests := []struct {
+		name string
+		env  map[string]string
+		cond string
+		want bool
+	}{
+		{
+			name: "should hold when the daemon has the variable :POS",
+			cond: "GHKD_TEST_SESSION",
+			want: true,
+		},
+		{
+			name: "should hold when the value matches :POS",
+			cond: "GHKD_TEST_SESSION=wayland",
+			want: true,
+		},
+		{
+			name: "should prefer the binding's env :POS",
+			env:  map[string]string{"GHKD_TEST_SESSION": "x11"},
+			cond: "GHKD_TEST_SESSION=x11",
+			want: true,
+		},
+		{
+			name: "should not hold when the value differs :NEG",
+			cond: "GHKD_TEST_SESSION=x11",
+			want: false,
+		},
+		{
+			name: "should not hold when the variable is empty :NEG",
+			cond: "GHKD_TEST_EMPTY",
+			want: false,
+		},
+		{
+			name: "should not hold when the variable is unset :NEG",
+			cond: "GHKD_TEST_UNSET",
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		kb := &config.Keybinding{Name: "Test", Env: tt.env}
+		assert.Equal(t, tt.want, envMatches(kb, tt.cond), tt.name)
+	}
+}
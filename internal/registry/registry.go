@@ -2,40 +2,135 @@ package registry
 
 import (
 	"sync"
+	"time"
 
 	"github.com/glowfi/ghkd/internal/config"
+	"github.com/glowfi/ghkd/internal/hotkey"
 )
 
 type Registry struct {
 	mu       sync.RWMutex // Read-Write Mutex
 	bindings []config.Keybinding
+	mode     string          // Only bindings of this mode match
+	paused   bool            // Only resume and toggle-pause bindings match
+	disabled map[string]bool // Bindings turned off by name
 }
 
 // NewRegistry creates a new registry
 func NewRegistry(bindings []config.Keybinding) *Registry {
 	return &Registry{
 		bindings: bindings,
+		mode:     config.DefaultMode,
+		disabled: make(map[string]bool),
 	}
 }
 
-// Update replaces the current keybindings with new ones (Thread-Safe)
+// Update replaces the current keybindings with new ones (Thread-Safe).
+// The mode, pause and disabled bindings are kept, unless the mode is gone
+// from the new config.
 func (r *Registry) Update(bindings []config.Keybinding) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 	r.bindings = bindings
+
+	for _, kb := range bindings {
+		if kb.ModeName() == r.mode {
+			return
+		}
+	}
+	r.mode = config.DefaultMode
+}
+
+// Match finds a keybinding that fires on event, one of config.OnPress,
+// OnRelease or OnHold, for the key snapshot (Thread-Safe). Bindings for the
+// keys are tried by priority and the first whose condition holds wins;
+// check runs their 'when' commands. As commands may take a while, Match
+// should not be called from the event loop.
+func (r *Registry) Match(snapshot hotkey.Snapshot, event string, check CommandCheck) *config.Keybinding {
+	// Conditions may run commands, they are checked without the lock
+	now := time.Now()
+	for _, kb := range r.candidates(snapshot, event) {
+		if holds(kb, now, check) {
+			return kb
+		}
+	}
+	return nil
 }
 
-// Match finds a keybinding matching pressed keys (Thread-Safe)
-func (r *Registry) Match(pressed []uint16) *config.Keybinding {
+// candidates returns the active bindings for the pressed keys and event,
+// ordered by priority (Thread-Safe)
+func (r *Registry) candidates(snapshot hotkey.Snapshot, event string) []*config.Keybinding {
 	r.mu.RLock() // Read lock allows multiple readers, blocks writers
 	defer r.mu.RUnlock()
 
+	var candidates []*config.Keybinding
 	for i := range r.bindings {
 		// Use pointer to avoid copying
 		kb := &r.bindings[i]
-		if kb.KeyCombination.Matches(pressed) {
-			return kb
+		if !r.active(kb) || kb.Event() != event {
+			continue
+		}
+		if kb.KeyCombination.Matches(snapshot.Keys) {
+			candidates = append(candidates, kb)
 		}
 	}
-	return nil
+
+	byPriority(candidates)
+	return candidates
+}
+
+// active reports whether a binding may match in the current state. Must be
+// called with r.mu held.
+func (r *Registry) active(kb *config.Keybinding) bool {
+	if r.disabled[kb.Name] || kb.ModeName() != r.mode {
+		return false
+	}
+	if !r.paused {
+		return true
+	}
+
+	// A paused daemon must still be able to resume
+	verb, _, _ := config.ParseAction(kb.Action)
+	return verb == config.ActionResume || verb == config.ActionTogglePause
+}
+
+// SetMode switches the active mode (Thread-Safe)
+func (r *Registry) SetMode(mode string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.mode = mode
+}
+
+// Mode returns the active mode (Thread-Safe)
+func (r *Registry) Mode() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.mode
+}
+
+// SetPaused pauses or resumes every binding (Thread-Safe)
+func (r *Registry) SetPaused(paused bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.paused = paused
+}
+
+// TogglePaused flips the pause and returns the new state (Thread-Safe)
+func (r *Registry) TogglePaused() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.paused = !r.paused
+	return r.paused
+}
+
+// SetEnabled turns a binding on or off by name (Thread-Safe)
+func (r *Registry) SetEnabled(name string, enabled bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if enabled {
+		delete(r.disabled, name)
+		return
+	}
+	r.disabled[name] = true
 }
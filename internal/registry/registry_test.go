@@ -0,0 +1,92 @@
+package registry
+
+import (
+	"testing"
+
+	"github.com/glowfi/ghkd/internal/config"
+	"github.com/glowfi/ghkd/internal/hotkey"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry_Match(t *testing.T) {
+	combo, err := hotkey.ParseKeyCombo("super+t")
+	require.NoError(t, err, "expect combo to parse")
+	snapshot := hotkey.Snapshot{Keys: []uint16{hotkey.KEY_LEFTMETA, hotkey.KEY_T}}
+
+	binding := func(name string, priority int, when *config.When) config.Keybinding {
+		return config.Keybinding{Name: name, KeyCombination: combo, Priority: priority, When: when}
+	}
+
+	tests := []struct {
+		name      string
+		bindings  []config.Keybinding
+		succeeds  map[string]bool // exit of each 'when' command
+		event     string
+		wantMatch string // empty for no match
+	}{
+		{
+			name: "should pick the highest priority whose condition holds :POS",
+			bindings: []config.Keybinding{
+				binding("Fallback", 0, nil),
+				binding("Steam", 10, &config.When{Command: "pgrep -x steam"}),
+				binding("Game", 5, &config.When{Command: "pgrep -x game"}),
+			},
+			succeeds:  map[string]bool{"pgrep -x steam": true, "pgrep -x game": true},
+			event:     config.OnPress,
+			wantMatch: "Steam",
+		},
+		{
+			name: "should fall through to lower priorities :POS",
+			bindings: []config.Keybinding{
+				binding("Fallback", 0, nil),
+				binding("Steam", 10, &config.When{Command: "pgrep -x steam"}),
+				binding("Game", 5, &config.When{Command: "pgrep -x game"}),
+			},
+			succeeds:  map[string]bool{"pgrep -x game": true},
+			event:     config.OnPress,
+			wantMatch: "Game",
+		},
+		{
+			name: "should fall through to the unguarded binding :POS",
+			bindings: []config.Keybinding{
+				binding("Steam", 10, &config.When{Command: "pgrep -x steam"}),
+				binding("Fallback", 0, nil),
+			},
+			succeeds:  map[string]bool{},
+			event:     config.OnPress,
+			wantMatch: "Fallback",
+		},
+		{
+			name: "should not match when no condition holds :NEG",
+			bindings: []config.Keybinding{
+				binding("Steam", 10, &config.When{Command: "pgrep -x steam"}),
+			},
+			succeeds:  map[string]bool{},
+			event:     config.OnPress,
+			wantMatch: "",
+		},
+		{
+			name: "should not match bindings of another event :NEG",
+			bindings: []config.Keybinding{
+				binding("Fallback", 0, nil),
+			},
+			event:     config.OnRelease,
+			wantMatch: "",
+		},
+	}
+
+	for _, tt := range tests {
+		reg := NewRegistry(tt.bindings)
+		match := reg.Match(snapshot, tt.event, func(kb *config.Keybinding, command string) bool {
+			return tt.succeeds[command]
+		})
+
+		if tt.wantMatch == "" {
+			assert.Nil(t, match, tt.name)
+			continue
+		}
+		require.NotNil(t, match, tt.name)
+		assert.Equal(t, tt.wantMatch, match.Name, tt.name)
+	}
+}
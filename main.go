@@ -8,6 +8,7 @@ import (
 
 	"github.com/glowfi/ghkd/internal/app"
 	"github.com/glowfi/ghkd/internal/cli"
+	"github.com/glowfi/ghkd/internal/ipc"
 )
 
 func main() {
@@ -24,6 +25,14 @@ func main() {
 	inputDir := "/dev/input"
 	pidFilePath := filepath.Join(os.TempDir(), "ghkd.pid")
 	appConfig := app.NewConfig(inputDir, opts.ConfigPath, pidFilePath)
+	appConfig.SocketPaths = ipc.SocketPaths()
+	appConfig.ReplayPath = opts.ReplayPath
+	appConfig.TracePath = opts.TracePath
+	appConfig.MaskTrace = opts.MaskTrace
+	appConfig.Binding = opts.Binding
+	appConfig.Runs = opts.Runs
+	appConfig.SetEnv = opts.SetEnv
+	appConfig.JSON = opts.JSON
 	daemon := app.NewDaemon(appConfig)
 
 	// Handle command (version, kill, reload, background)